@@ -0,0 +1,357 @@
+/*
+Copyright 2019 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit implements a heuristic linter over parsed spansql queries
+// and schemas: a set of Rules that each look for one common mistake and
+// report it as a Finding.
+//
+// These rules are heuristics, not a guarantee of correctness or of a
+// performance problem; they flag patterns worth a second look; rules such as
+// FunctionWrappedPredicate and OrderByWithLimit can't see the schema's
+// indexes from a single query, so they flag the pattern unconditionally
+// rather than trying to decide whether it actually costs an index.
+package audit
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner/spansql"
+)
+
+// Severity is how serious a Finding is.
+type Severity int
+
+const (
+	// Notice is a style or efficiency suggestion.
+	Notice Severity = iota
+	// Warning is a pattern that is very likely to be a mistake.
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	default:
+		return "notice"
+	}
+}
+
+// Finding is a single rule violation.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Message  string
+
+	// Position is the location of the offending statement, if node was a
+	// spansql.DDL; it is the zero Position for a finding against a query,
+	// since spansql doesn't record a query's source location.
+	Position spansql.Position
+}
+
+func (f Finding) String() string {
+	if f.Position == (spansql.Position{}) {
+		return fmt.Sprintf("[%s] %s: %s", f.Severity, f.Rule, f.Message)
+	}
+	return fmt.Sprintf("%d:%d: [%s] %s: %s", f.Position.Line, f.Position.Column, f.Severity, f.Rule, f.Message)
+}
+
+// Findings is a list of Finding, in the order their rules reported them.
+type Findings []Finding
+
+// BySeverity returns the findings with severity at least min.
+func (fs Findings) BySeverity(min Severity) Findings {
+	var out Findings
+	for _, f := range fs {
+		if f.Severity >= min {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Rule is a single audit check that can be run against a parsed query or
+// schema.
+type Rule interface {
+	// Name is a short, stable identifier for the rule, such as "select-star".
+	Name() string
+	// Check inspects node, which is a spansql.Query or a spansql.DDL, and
+	// reports any violations it finds. A rule that doesn't apply to node's
+	// type returns nil.
+	Check(node interface{}) []Finding
+}
+
+// DefaultRules is the starter set of rules shipped by this package.
+var DefaultRules = []Rule{
+	MissingLimit{},
+	SelectStar{},
+	LeadingWildcardLike{},
+	FunctionWrappedPredicate{},
+	OrderByWithLimit{},
+	WideCompositeKey{},
+	UnboundedStringKeyColumn{},
+}
+
+// Check runs every rule in rules against node, which must be a spansql.Query
+// or a spansql.DDL, and returns their combined findings.
+func Check(node interface{}, rules []Rule) Findings {
+	var out Findings
+	for _, r := range rules {
+		out = append(out, r.Check(node)...)
+	}
+	return out
+}
+
+// walkExpr calls visit on e and every expression nested within it. It does
+// not descend into subqueries.
+func walkExpr(e spansql.Expr, visit func(spansql.Expr)) {
+	if e == nil {
+		return
+	}
+	visit(e)
+	switch v := e.(type) {
+	case spansql.LogicalOp:
+		walkExpr(v.LHS, visit)
+		walkExpr(v.RHS, visit)
+	case spansql.ComparisonOp:
+		walkExpr(v.LHS, visit)
+		walkExpr(v.RHS, visit)
+	case spansql.IsOp:
+		walkExpr(v.LHS, visit)
+		walkExpr(v.RHS, visit)
+	case spansql.InOp:
+		walkExpr(v.LHS, visit)
+		for _, val := range v.Values {
+			walkExpr(val, visit)
+		}
+	case spansql.FuncCall:
+		for _, a := range v.Args {
+			walkExpr(a, visit)
+		}
+	}
+}
+
+// MissingLimit flags a SELECT with no LIMIT clause, which can return an
+// unbounded result set.
+type MissingLimit struct{}
+
+func (MissingLimit) Name() string { return "missing-limit" }
+
+func (MissingLimit) Check(node interface{}) []Finding {
+	q, ok := node.(spansql.Query)
+	if !ok || q.Limit != nil {
+		return nil
+	}
+	return []Finding{{
+		Rule:     "missing-limit",
+		Severity: Notice,
+		Message:  "query has no LIMIT clause and may return an unbounded result set",
+	}}
+}
+
+// SelectStar flags a bare "SELECT *", which fetches every column even when
+// only a few of them are needed.
+type SelectStar struct{}
+
+func (SelectStar) Name() string { return "select-star" }
+
+func (SelectStar) Check(node interface{}) []Finding {
+	q, ok := node.(spansql.Query)
+	if !ok {
+		return nil
+	}
+	for _, e := range q.Select.List {
+		if e == spansql.Star {
+			return []Finding{{
+				Rule:     "select-star",
+				Severity: Notice,
+				Message:  `"SELECT *" fetches every column; list only the columns you need`,
+			}}
+		}
+	}
+	return nil
+}
+
+// LeadingWildcardLike flags a LIKE pattern with a leading "%", which can't
+// use an index and forces a full scan of the values it's applied to.
+type LeadingWildcardLike struct{}
+
+func (LeadingWildcardLike) Name() string { return "leading-wildcard-like" }
+
+func (LeadingWildcardLike) Check(node interface{}) []Finding {
+	q, ok := node.(spansql.Query)
+	if !ok {
+		return nil
+	}
+	var findings []Finding
+	check := func(e spansql.Expr) {
+		cmp, ok := e.(spansql.ComparisonOp)
+		if !ok || (cmp.Op != spansql.Like && cmp.Op != spansql.NotLike) {
+			return
+		}
+		lit, ok := cmp.RHS.(spansql.StringLiteral)
+		if !ok || !strings.HasPrefix(string(lit), "%") {
+			return
+		}
+		findings = append(findings, Finding{
+			Rule:     "leading-wildcard-like",
+			Severity: Warning,
+			Message:  fmt.Sprintf("LIKE pattern %q starts with %%, which can't use an index", string(lit)),
+		})
+	}
+	walkExpr(q.Select.Where, check)
+	walkExpr(q.Select.Having, check)
+	return findings
+}
+
+// FunctionWrappedPredicate flags a WHERE predicate that wraps a column in a
+// function call, such as "WHERE LOWER(Name) = \"eve\"". This is a common way
+// to accidentally defeat an index, since Spanner can't use a standard index
+// to satisfy a predicate on a value derived from a column rather than the
+// column itself.
+type FunctionWrappedPredicate struct{}
+
+func (FunctionWrappedPredicate) Name() string { return "function-wrapped-predicate" }
+
+func (FunctionWrappedPredicate) Check(node interface{}) []Finding {
+	q, ok := node.(spansql.Query)
+	if !ok {
+		return nil
+	}
+	// wrapsColumn reports whether e is a function call with a column
+	// reference somewhere among its arguments.
+	wrapsColumn := func(e spansql.Expr) bool {
+		if _, ok := e.(spansql.FuncCall); !ok {
+			return false
+		}
+		found := false
+		walkExpr(e, func(x spansql.Expr) {
+			if _, ok := x.(spansql.ID); ok {
+				found = true
+			}
+		})
+		return found
+	}
+	var findings []Finding
+	walkExpr(q.Select.Where, func(e spansql.Expr) {
+		cmp, ok := e.(spansql.ComparisonOp)
+		if !ok || !(wrapsColumn(cmp.LHS) || wrapsColumn(cmp.RHS)) {
+			return
+		}
+		findings = append(findings, Finding{
+			Rule:     "function-wrapped-predicate",
+			Severity: Notice,
+			Message:  "WHERE clause wraps a column in a function call, which may prevent Spanner from using an index on it",
+		})
+	})
+	return findings
+}
+
+// OrderByWithLimit flags a query that combines ORDER BY with LIMIT. This is
+// efficient when the sort order is served by an index, but it's worth
+// double-checking: if it isn't, Spanner must read and sort the whole result
+// before truncating it.
+type OrderByWithLimit struct{}
+
+func (OrderByWithLimit) Name() string { return "order-by-with-limit" }
+
+func (OrderByWithLimit) Check(node interface{}) []Finding {
+	q, ok := node.(spansql.Query)
+	if !ok || len(q.Order) == 0 || q.Limit == nil {
+		return nil
+	}
+	return []Finding{{
+		Rule:     "order-by-with-limit",
+		Severity: Notice,
+		Message:  "ORDER BY combined with LIMIT; confirm the sort column is indexed, or Spanner must sort the full result before truncating it",
+	}}
+}
+
+// DefaultMaxKeyParts is the default threshold used by WideCompositeKey.
+const DefaultMaxKeyParts = 4
+
+// WideCompositeKey flags a table whose primary key has more than MaxParts
+// columns, which tends to indicate a key that's awkward to use and slow to
+// maintain. MaxParts defaults to DefaultMaxKeyParts if zero.
+type WideCompositeKey struct {
+	MaxParts int
+}
+
+func (WideCompositeKey) Name() string { return "wide-composite-key" }
+
+func (r WideCompositeKey) Check(node interface{}) []Finding {
+	ddl, ok := node.(spansql.DDL)
+	if !ok {
+		return nil
+	}
+	max := r.MaxParts
+	if max == 0 {
+		max = DefaultMaxKeyParts
+	}
+	var findings []Finding
+	for _, stmt := range ddl.List {
+		ct, ok := stmt.(spansql.CreateTable)
+		if !ok || len(ct.PrimaryKey) <= max {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule:     "wide-composite-key",
+			Severity: Warning,
+			Message:  fmt.Sprintf("table %s has a %d-part primary key, more than the recommended %d", ct.Name, len(ct.PrimaryKey), max),
+			Position: ct.Position,
+		})
+	}
+	return findings
+}
+
+// UnboundedStringKeyColumn flags a primary key column declared STRING(MAX),
+// where a bounded length would let Spanner validate and store keys more
+// efficiently.
+type UnboundedStringKeyColumn struct{}
+
+func (UnboundedStringKeyColumn) Name() string { return "unbounded-string-key-column" }
+
+func (UnboundedStringKeyColumn) Check(node interface{}) []Finding {
+	ddl, ok := node.(spansql.DDL)
+	if !ok {
+		return nil
+	}
+	var findings []Finding
+	for _, stmt := range ddl.List {
+		ct, ok := stmt.(spansql.CreateTable)
+		if !ok {
+			continue
+		}
+		cols := make(map[string]spansql.ColumnDef)
+		for _, c := range ct.Columns {
+			cols[c.Name] = c
+		}
+		for _, kp := range ct.PrimaryKey {
+			c, ok := cols[kp.Column]
+			if !ok || c.Type.Base != spansql.String || c.Type.Len != spansql.MaxLen {
+				continue
+			}
+			findings = append(findings, Finding{
+				Rule:     "unbounded-string-key-column",
+				Severity: Notice,
+				Message:  fmt.Sprintf("table %s: primary key column %s is STRING(MAX); a bounded length would suffice for a key", ct.Name, kp.Column),
+				Position: ct.Position,
+			})
+		}
+	}
+	return findings
+}
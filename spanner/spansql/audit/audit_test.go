@@ -0,0 +1,192 @@
+/*
+Copyright 2019 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner/spansql"
+)
+
+func ruleNames(fs Findings) []string {
+	var names []string
+	for _, f := range fs {
+		names = append(names, f.Rule)
+	}
+	return names
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMissingLimit(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{`SELECT SingerID FROM Singers LIMIT 10`, false},
+		{`SELECT SingerID FROM Singers`, true},
+	}
+	for _, test := range tests {
+		q, err := spansql.ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		got := contains(ruleNames(MissingLimit{}.Check(q)), "missing-limit")
+		if got != test.want {
+			t.Errorf("MissingLimit.Check(%q) flagged = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestSelectStar(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{`SELECT * FROM Singers`, true},
+		{`SELECT SingerID FROM Singers`, false},
+	}
+	for _, test := range tests {
+		q, err := spansql.ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		got := contains(ruleNames(SelectStar{}.Check(q)), "select-star")
+		if got != test.want {
+			t.Errorf("SelectStar.Check(%q) flagged = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestLeadingWildcardLike(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{`SELECT SingerID FROM Singers WHERE Name LIKE "%Smith"`, true},
+		{`SELECT SingerID FROM Singers WHERE Name LIKE "Smith%"`, false},
+	}
+	for _, test := range tests {
+		q, err := spansql.ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		got := contains(ruleNames(LeadingWildcardLike{}.Check(q)), "leading-wildcard-like")
+		if got != test.want {
+			t.Errorf("LeadingWildcardLike.Check(%q) flagged = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestFunctionWrappedPredicate(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{`SELECT SingerID FROM Singers WHERE LOWER(Name) = "eve"`, true},
+		{`SELECT SingerID FROM Singers WHERE Name = "Eve"`, false},
+	}
+	for _, test := range tests {
+		q, err := spansql.ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		got := contains(ruleNames(FunctionWrappedPredicate{}.Check(q)), "function-wrapped-predicate")
+		if got != test.want {
+			t.Errorf("FunctionWrappedPredicate.Check(%q) flagged = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestOrderByWithLimit(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{`SELECT SingerID FROM Singers ORDER BY Name LIMIT 10`, true},
+		{`SELECT SingerID FROM Singers ORDER BY Name`, false},
+		{`SELECT SingerID FROM Singers LIMIT 10`, false},
+	}
+	for _, test := range tests {
+		q, err := spansql.ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		got := contains(ruleNames(OrderByWithLimit{}.Check(q)), "order-by-with-limit")
+		if got != test.want {
+			t.Errorf("OrderByWithLimit.Check(%q) flagged = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestWideCompositeKey(t *testing.T) {
+	tests := []struct {
+		ddl  string
+		want bool
+	}{
+		{`CREATE TABLE T (A INT64, B INT64, C INT64, D INT64, E INT64) PRIMARY KEY (A, B, C, D, E)`, true},
+		{`CREATE TABLE T (A INT64, B INT64) PRIMARY KEY (A, B)`, false},
+	}
+	for _, test := range tests {
+		d, err := spansql.ParseDDL(test.ddl)
+		if err != nil {
+			t.Fatalf("ParseDDL(%q): %v", test.ddl, err)
+		}
+		got := contains(ruleNames(WideCompositeKey{}.Check(d)), "wide-composite-key")
+		if got != test.want {
+			t.Errorf("WideCompositeKey.Check(%q) flagged = %v, want %v", test.ddl, got, test.want)
+		}
+	}
+}
+
+func TestUnboundedStringKeyColumn(t *testing.T) {
+	tests := []struct {
+		ddl  string
+		want bool
+	}{
+		{`CREATE TABLE T (A STRING(MAX)) PRIMARY KEY (A)`, true},
+		{`CREATE TABLE T (A STRING(36)) PRIMARY KEY (A)`, false},
+	}
+	for _, test := range tests {
+		d, err := spansql.ParseDDL(test.ddl)
+		if err != nil {
+			t.Fatalf("ParseDDL(%q): %v", test.ddl, err)
+		}
+		got := contains(ruleNames(UnboundedStringKeyColumn{}.Check(d)), "unbounded-string-key-column")
+		if got != test.want {
+			t.Errorf("UnboundedStringKeyColumn.Check(%q) flagged = %v, want %v", test.ddl, got, test.want)
+		}
+	}
+}
+
+func TestCheckDefaultRules(t *testing.T) {
+	q, err := spansql.ParseQuery(`SELECT * FROM Singers`)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	got := Check(q, DefaultRules)
+	if !contains(ruleNames(got), "select-star") || !contains(ruleNames(got), "missing-limit") {
+		t.Errorf("Check(%v, DefaultRules) = %v, want select-star and missing-limit", q, got)
+	}
+}
@@ -0,0 +1,448 @@
+/*
+Copyright 2019 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spansql
+
+// This file implements Diff, which compares two schemas expressed as DDL and
+// produces the DDL statements needed to migrate from one to the other.
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// UnsupportedDiffError reports a schema change that Diff cannot express as a
+// sequence of ALTER statements, either because Spanner forbids it (changing
+// a table's primary key, adding, removing or re-parenting its INTERLEAVE IN
+// PARENT clause, or changing its ON DELETE action from NO ACTION back to
+// CASCADE) or because it isn't safely representable (changing a column's
+// base type or array-ness, or narrowing a STRING/BYTES length).
+type UnsupportedDiffError struct {
+	Table string
+	Msg   string
+}
+
+func (e *UnsupportedDiffError) Error() string {
+	return fmt.Sprintf("spansql: table %s: %s", e.Table, e.Msg)
+}
+
+// Diff compares the old and new schemas and returns the sequence of DDL
+// statements that transforms old into new. Tables and indexes that only
+// appear in new are created; those that only appear in old are dropped
+// (dropping a table's indexes first). Tables present in both are compared
+// column-by-column and constraint-by-constraint to produce the minimal set
+// of ALTER TABLE statements, with any index whose definition depends on a
+// changed column dropped before the change and recreated afterwards.
+//
+// Diff returns an *UnsupportedDiffError if new gives a common table a
+// different primary key, changes a column in a way that cannot be
+// expressed as an ALTER COLUMN (a change of base type or array-ness, or a
+// narrower STRING/BYTES length), or adds, removes or re-parents an INTERLEAVE IN
+// PARENT clause; a change to only the clause's ON DELETE action is
+// expressed as a SET ON DELETE alteration, except that NO ACTION cannot be
+// changed back to CASCADE.
+func Diff(old, new DDL) ([]DDLStmt, error) {
+	oldTables, oldOrder := tablesByName(old)
+	newTables, newOrder := tablesByName(new)
+	oldIndexes := indexesByTable(old)
+	newIndexes := indexesByTable(new)
+
+	var stmts []DDLStmt
+
+	// Tables removed: drop their indexes, then the table itself.
+	for _, name := range oldOrder {
+		if _, ok := newTables[name]; ok {
+			continue
+		}
+		for _, ci := range oldIndexes[name] {
+			stmts = append(stmts, DropIndex{Name: ci.Name})
+		}
+		stmts = append(stmts, DropTable{Name: name})
+	}
+
+	// Tables present in both: diff their contents.
+	for _, name := range oldOrder {
+		nt, ok := newTables[name]
+		if !ok {
+			continue
+		}
+		ot := oldTables[name]
+		ts, err := diffTable(ot, nt, oldIndexes[name], newIndexes[name])
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, ts...)
+	}
+
+	// Tables added: create the table, then its indexes.
+	for _, name := range newOrder {
+		if _, ok := oldTables[name]; ok {
+			continue
+		}
+		nt := newTables[name]
+		stmts = append(stmts, nt)
+		for _, ci := range newIndexes[name] {
+			stmts = append(stmts, ci)
+		}
+	}
+
+	return stmts, nil
+}
+
+// DiffSQL parses old and new as DDL and returns the SQL text of the
+// statements returned by Diff.
+func DiffSQL(old, new string) (string, error) {
+	oldDDL, err := ParseDDL(old)
+	if err != nil {
+		return "", fmt.Errorf("parsing old schema: %v", err)
+	}
+	newDDL, err := ParseDDL(new)
+	if err != nil {
+		return "", fmt.Errorf("parsing new schema: %v", err)
+	}
+	stmts, err := Diff(oldDDL, newDDL)
+	if err != nil {
+		return "", err
+	}
+	return DDL{List: stmts}.SQL(), nil
+}
+
+// tablesByName returns the CreateTable statements in d keyed by name, along
+// with the table names in the order they were created.
+func tablesByName(d DDL) (map[string]CreateTable, []string) {
+	m := make(map[string]CreateTable)
+	var order []string
+	for _, stmt := range d.List {
+		if ct, ok := stmt.(CreateTable); ok {
+			m[ct.Name] = ct
+			order = append(order, ct.Name)
+		}
+	}
+	return m, order
+}
+
+// indexesByTable returns the CreateIndex statements in d grouped by the
+// table they index, preserving creation order within each table.
+func indexesByTable(d DDL) map[string][]CreateIndex {
+	m := make(map[string][]CreateIndex)
+	for _, stmt := range d.List {
+		if ci, ok := stmt.(CreateIndex); ok {
+			m[ci.Table] = append(m[ci.Table], ci)
+		}
+	}
+	return m
+}
+
+// diffTable returns the ALTER TABLE / CREATE INDEX / DROP INDEX statements
+// needed to transform ot (with its current indexes oldIdx) into nt (with
+// target indexes newIdx).
+func diffTable(ot, nt CreateTable, oldIdx, newIdx []CreateIndex) ([]DDLStmt, error) {
+	if !reflect.DeepEqual(ot.PrimaryKey, nt.PrimaryKey) {
+		return nil, &UnsupportedDiffError{Table: ot.Name, Msg: "primary key cannot be changed"}
+	}
+
+	onDeleteAlt, err := diffInterleave(ot, nt)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, added, renamed, altered, err := diffColumns(ot.Name, ot.Columns, nt.Columns)
+	if err != nil {
+		return nil, err
+	}
+	changedCols := make(map[string]bool) // names, in ot's naming, touched by a rename or alter
+	for _, r := range renamed {
+		changedCols[r.From] = true
+	}
+	for _, a := range altered {
+		changedCols[a.Name] = true
+	}
+	for _, name := range removed {
+		changedCols[name] = true
+	}
+
+	dropIdx, createIdx := diffIndexes(oldIdx, newIdx, changedCols)
+
+	var stmts []DDLStmt
+	for _, ci := range dropIdx {
+		stmts = append(stmts, DropIndex{Name: ci})
+	}
+
+	if onDeleteAlt != nil {
+		stmts = append(stmts, AlterTable{Name: ot.Name, Alteration: onDeleteAlt})
+	}
+
+	dropCons, addCons := diffConstraints(ot.Constraints, nt.Constraints)
+	for _, name := range dropCons {
+		stmts = append(stmts, AlterTable{Name: ot.Name, Alteration: DropConstraint{Name: name}})
+	}
+
+	for _, name := range removed {
+		stmts = append(stmts, AlterTable{Name: ot.Name, Alteration: DropColumn{Name: name}})
+	}
+	for _, rc := range renamed {
+		stmts = append(stmts, AlterTable{Name: ot.Name, Alteration: rc})
+	}
+	for _, ac := range altered {
+		stmts = append(stmts, AlterTable{Name: ot.Name, Alteration: ac})
+	}
+	for _, name := range added {
+		cd := columnByName(nt.Columns, name)
+		stmts = append(stmts, AlterTable{Name: ot.Name, Alteration: AddColumn{Def: cd}})
+	}
+
+	for _, tc := range addCons {
+		stmts = append(stmts, AlterTable{Name: ot.Name, Alteration: AddConstraint{Constraint: tc}})
+	}
+
+	for _, ci := range createIdx {
+		stmts = append(stmts, ci)
+	}
+
+	return stmts, nil
+}
+
+// diffInterleave compares ot and nt's Interleave clauses and returns the
+// Alteration needed to change the ON DELETE action between them, or nil if
+// the clauses are identical. It returns an *UnsupportedDiffError if the
+// interleave parent is added, removed or changed, or if the ON DELETE action
+// changes in a direction Spanner has no ALTER TABLE statement for (there is
+// no way to turn NO ACTION back into CASCADE after a table is created).
+func diffInterleave(ot, nt CreateTable) (Alteration, error) {
+	switch {
+	case ot.Interleave == nil && nt.Interleave == nil:
+		return nil, nil
+	case ot.Interleave == nil || nt.Interleave == nil:
+		return nil, &UnsupportedDiffError{Table: ot.Name, Msg: "interleaving cannot be added or removed"}
+	case ot.Interleave.Parent != nt.Interleave.Parent:
+		return nil, &UnsupportedDiffError{Table: ot.Name, Msg: "interleave parent cannot be changed"}
+	case ot.Interleave.OnDelete == nt.Interleave.OnDelete:
+		return nil, nil
+	case nt.Interleave.OnDelete == OnDeleteNoAction:
+		return NoActionOnDelete, nil
+	default:
+		return nil, &UnsupportedDiffError{Table: ot.Name, Msg: "interleave ON DELETE action cannot be changed back to CASCADE"}
+	}
+}
+
+func columnByName(cols []ColumnDef, name string) ColumnDef {
+	for _, c := range cols {
+		if c.Name == name {
+			return c
+		}
+	}
+	return ColumnDef{}
+}
+
+// diffColumns compares an old and new column list by name, falling back to
+// matching leftover columns by position (to detect a rename) when a name
+// disappears from one side and a new one appears at the same offset with an
+// identical type. It returns the names of columns to drop and add, the
+// RenameColumn/AlterColumn alterations needed, or an error if a retained
+// column's type changed in a way that isn't safely representable.
+func diffColumns(table string, oldCols, newCols []ColumnDef) (removed, added []string, renamed []RenameColumn, altered []AlterColumn, err error) {
+	oldByName := make(map[string]ColumnDef)
+	for _, c := range oldCols {
+		oldByName[c.Name] = c
+	}
+	newByName := make(map[string]ColumnDef)
+	for _, c := range newCols {
+		newByName[c.Name] = c
+	}
+
+	var leftoverOld, leftoverNew []ColumnDef
+	for _, c := range oldCols {
+		if _, ok := newByName[c.Name]; !ok {
+			leftoverOld = append(leftoverOld, c)
+		}
+	}
+	for _, c := range newCols {
+		if _, ok := oldByName[c.Name]; !ok {
+			leftoverNew = append(leftoverNew, c)
+		}
+	}
+
+	// Leftover columns at the same position with an identical type are
+	// treated as a rename rather than a drop-and-add.
+	n := len(leftoverOld)
+	if len(leftoverNew) < n {
+		n = len(leftoverNew)
+	}
+	matched := 0
+	for i := 0; i < n; i++ {
+		o, nw := leftoverOld[i], leftoverNew[i]
+		if !reflect.DeepEqual(o.Type, nw.Type) {
+			break
+		}
+		renamed = append(renamed, RenameColumn{From: o.Name, To: nw.Name})
+		if o.NotNull != nw.NotNull {
+			altered = append(altered, AlterColumn{Name: nw.Name, Type: nw.Type, NotNull: nw.NotNull})
+		}
+		matched++
+	}
+	for _, c := range leftoverOld[matched:] {
+		removed = append(removed, c.Name)
+	}
+	for _, c := range leftoverNew[matched:] {
+		added = append(added, c.Name)
+	}
+
+	for _, nc := range newCols {
+		oc, ok := oldByName[nc.Name]
+		if !ok {
+			continue // handled above, as an add or a rename
+		}
+		if reflect.DeepEqual(oc, nc) {
+			continue
+		}
+		if !columnTypeChangeSafe(oc.Type, nc.Type) {
+			return nil, nil, nil, nil, &UnsupportedDiffError{
+				Table: table,
+				Msg:   fmt.Sprintf("column %s cannot be changed from %s to %s", nc.Name, oc.Type.SQL(), nc.Type.SQL()),
+			}
+		}
+		altered = append(altered, AlterColumn{Name: nc.Name, Type: nc.Type, NotNull: nc.NotNull})
+	}
+
+	return removed, added, renamed, altered, nil
+}
+
+// columnTypeChangeSafe reports whether a column can change from "from" to
+// "to" via ALTER COLUMN: the array-ness and base type must match, and a
+// STRING/BYTES length may only grow, never shrink.
+func columnTypeChangeSafe(from, to Type) bool {
+	if from.Array != to.Array || from.Base != to.Base {
+		return false
+	}
+	if from.Base == String || from.Base == Bytes {
+		return to.Len >= from.Len
+	}
+	return true
+}
+
+// diffIndexes compares an old and new set of indexes on the same table,
+// matching by index name. changedCols holds the old column names touched by
+// a rename, alter, or drop; any index covering one of those columns is
+// dropped and, if it still exists in new, recreated afterwards, since
+// Spanner forbids altering a column that an index depends on.
+func diffIndexes(oldIdx, newIdx []CreateIndex, changedCols map[string]bool) (drop []string, create []CreateIndex) {
+	oldByName := make(map[string]CreateIndex)
+	for _, ci := range oldIdx {
+		oldByName[ci.Name] = ci
+	}
+	newByName := make(map[string]CreateIndex)
+	for _, ci := range newIdx {
+		newByName[ci.Name] = ci
+	}
+
+	for _, ci := range oldIdx {
+		nci, ok := newByName[ci.Name]
+		switch {
+		case !ok:
+			drop = append(drop, ci.Name)
+		case !reflect.DeepEqual(ci, nci):
+			drop = append(drop, ci.Name)
+		case indexDependsOn(ci, changedCols):
+			drop = append(drop, ci.Name)
+		}
+	}
+	for _, ci := range newIdx {
+		oci, ok := oldByName[ci.Name]
+		switch {
+		case !ok:
+			create = append(create, ci)
+		case !reflect.DeepEqual(oci, ci):
+			create = append(create, ci)
+		case indexDependsOn(ci, changedCols):
+			create = append(create, ci)
+		}
+	}
+	return drop, create
+}
+
+func indexDependsOn(ci CreateIndex, changedCols map[string]bool) bool {
+	for _, kp := range ci.Columns {
+		if changedCols[kp.Column] {
+			return true
+		}
+	}
+	return false
+}
+
+// diffConstraints compares an old and new set of table constraints, matching
+// named constraints by name and unnamed ones by exact definition. A named
+// constraint whose definition changed is dropped and recreated, since
+// Spanner has no way to alter a constraint in place; this is what surfaces a
+// changed ON DELETE action on a foreign key.
+func diffConstraints(old, new []TableConstraint) (drop []string, add []TableConstraint) {
+	oldNamed := make(map[string]TableConstraint)
+	var oldUnnamed []TableConstraint
+	for _, tc := range old {
+		if tc.Name != "" {
+			oldNamed[tc.Name] = tc
+		} else {
+			oldUnnamed = append(oldUnnamed, tc)
+		}
+	}
+	newNamed := make(map[string]TableConstraint)
+	var newUnnamed []TableConstraint
+	for _, tc := range new {
+		if tc.Name != "" {
+			newNamed[tc.Name] = tc
+		} else {
+			newUnnamed = append(newUnnamed, tc)
+		}
+	}
+
+	for _, tc := range old {
+		if tc.Name == "" {
+			continue
+		}
+		ntc, ok := newNamed[tc.Name]
+		if !ok || !reflect.DeepEqual(tc, ntc) {
+			drop = append(drop, tc.Name)
+		}
+	}
+	for _, ntc := range new {
+		if ntc.Name == "" {
+			continue
+		}
+		tc, ok := oldNamed[ntc.Name]
+		if !ok || !reflect.DeepEqual(tc, ntc) {
+			add = append(add, ntc)
+		}
+	}
+
+	used := make([]bool, len(oldUnnamed))
+	for _, ntc := range newUnnamed {
+		found := false
+		for i, tc := range oldUnnamed {
+			if !used[i] && reflect.DeepEqual(tc, ntc) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			add = append(add, ntc)
+		}
+	}
+	// Stale unnamed constraints in old with no match in new are left alone:
+	// Spanner has no DROP CONSTRAINT syntax for an unnamed constraint, so
+	// there's nothing to emit for their removal.
+
+	return drop, add
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2019 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spansql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffSQL(t *testing.T) {
+	tests := []struct {
+		desc     string
+		old, new string
+		want     string // SQL text of the expected statements, joined with ";\n"
+	}{
+		{
+			desc: "add table",
+			old:  `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID)`,
+			new: `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID);
+				CREATE TABLE Albums (AlbumID INT64 NOT NULL) PRIMARY KEY (AlbumID);
+				CREATE INDEX AlbumsByID ON Albums (AlbumID)`,
+			want: "CREATE TABLE Albums (AlbumID INT64 NOT NULL) PRIMARY KEY (AlbumID);\n" +
+				"CREATE INDEX AlbumsByID ON Albums (AlbumID)",
+		},
+		{
+			desc: "drop table with index",
+			old: `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID);
+				CREATE TABLE Albums (AlbumID INT64 NOT NULL) PRIMARY KEY (AlbumID);
+				CREATE INDEX AlbumsByID ON Albums (AlbumID)`,
+			new:  `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID)`,
+			want: "DROP INDEX AlbumsByID;\nDROP TABLE Albums",
+		},
+		{
+			desc: "add and drop column of different types",
+			old: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				FirstName STRING(MAX),
+			) PRIMARY KEY (SingerID)`,
+			new: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				YearsActive INT64,
+			) PRIMARY KEY (SingerID)`,
+			want: "ALTER TABLE Singers DROP COLUMN FirstName;\n" +
+				"ALTER TABLE Singers ADD COLUMN YearsActive INT64",
+		},
+		{
+			desc: "rename column by position",
+			old: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				FirstName STRING(MAX),
+			) PRIMARY KEY (SingerID)`,
+			new: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				GivenName STRING(MAX),
+			) PRIMARY KEY (SingerID)`,
+			want: "ALTER TABLE Singers RENAME COLUMN FirstName TO GivenName",
+		},
+		{
+			desc: "widen column type",
+			old: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				FirstName STRING(16),
+			) PRIMARY KEY (SingerID)`,
+			new: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				FirstName STRING(32) NOT NULL,
+			) PRIMARY KEY (SingerID)`,
+			want: "ALTER TABLE Singers ALTER COLUMN FirstName STRING(32) NOT NULL",
+		},
+		{
+			desc: "index drop and recreate around altered column",
+			old: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				FirstName STRING(16),
+			) PRIMARY KEY (SingerID);
+				CREATE INDEX SingersByName ON Singers (FirstName)`,
+			new: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				FirstName STRING(64),
+			) PRIMARY KEY (SingerID);
+				CREATE INDEX SingersByName ON Singers (FirstName)`,
+			want: "DROP INDEX SingersByName;\n" +
+				"ALTER TABLE Singers ALTER COLUMN FirstName STRING(64);\n" +
+				"CREATE INDEX SingersByName ON Singers (FirstName)",
+		},
+		{
+			desc: "foreign key ON DELETE change drops and recreates the constraint",
+			old: `CREATE TABLE Orders (
+				OrderID INT64 NOT NULL,
+				CustomerID INT64 NOT NULL,
+				CONSTRAINT FK_Customer FOREIGN KEY (CustomerID) REFERENCES Customers (CustomerID),
+			) PRIMARY KEY (OrderID)`,
+			new: `CREATE TABLE Orders (
+				OrderID INT64 NOT NULL,
+				CustomerID INT64 NOT NULL,
+				CONSTRAINT FK_Customer FOREIGN KEY (CustomerID) REFERENCES Customers (CustomerID) ON DELETE CASCADE,
+			) PRIMARY KEY (OrderID)`,
+			want: "ALTER TABLE Orders DROP CONSTRAINT FK_Customer;\n" +
+				"ALTER TABLE Orders ADD CONSTRAINT FK_Customer FOREIGN KEY (CustomerID) REFERENCES Customers (CustomerID) ON DELETE CASCADE",
+		},
+		{
+			desc: "no-op",
+			old:  `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID)`,
+			new:  `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID)`,
+			want: "",
+		},
+		{
+			desc: "interleave ON DELETE action changes to NO ACTION",
+			old: `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID);
+				CREATE TABLE Albums (
+					SingerID INT64 NOT NULL,
+					AlbumID INT64 NOT NULL,
+				) PRIMARY KEY (SingerID, AlbumID), INTERLEAVE IN PARENT Singers ON DELETE CASCADE`,
+			new: `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID);
+				CREATE TABLE Albums (
+					SingerID INT64 NOT NULL,
+					AlbumID INT64 NOT NULL,
+				) PRIMARY KEY (SingerID, AlbumID), INTERLEAVE IN PARENT Singers`,
+			want: "ALTER TABLE Albums SET ON DELETE NO ACTION",
+		},
+	}
+	for _, test := range tests {
+		got, err := DiffSQL(test.old, test.new)
+		if err != nil {
+			t.Errorf("%s: DiffSQL: %v", test.desc, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: DiffSQL = %q, want %q", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	tests := []struct {
+		desc     string
+		old, new string
+		wantSub  string // substring expected in the error
+	}{
+		{
+			desc: "primary key change is forbidden",
+			old:  `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID)`,
+			new: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				LastName STRING(MAX) NOT NULL,
+			) PRIMARY KEY (SingerID, LastName)`,
+			wantSub: "primary key cannot be changed",
+		},
+		{
+			desc: "base type change is unsafe",
+			old: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				FirstName STRING(MAX),
+			) PRIMARY KEY (SingerID)`,
+			new: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				FirstName INT64,
+			) PRIMARY KEY (SingerID)`,
+			wantSub: "cannot be changed",
+		},
+		{
+			desc: "narrowing a STRING column is unsafe",
+			old: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				FirstName STRING(64),
+			) PRIMARY KEY (SingerID)`,
+			new: `CREATE TABLE Singers (
+				SingerID INT64 NOT NULL,
+				FirstName STRING(16),
+			) PRIMARY KEY (SingerID)`,
+			wantSub: "cannot be changed",
+		},
+		{
+			desc: "adding interleave is forbidden",
+			old: `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID);
+				CREATE TABLE Albums (
+					SingerID INT64 NOT NULL,
+					AlbumID INT64 NOT NULL,
+				) PRIMARY KEY (SingerID, AlbumID)`,
+			new: `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID);
+				CREATE TABLE Albums (
+					SingerID INT64 NOT NULL,
+					AlbumID INT64 NOT NULL,
+				) PRIMARY KEY (SingerID, AlbumID), INTERLEAVE IN PARENT Singers`,
+			wantSub: "interleaving cannot be added or removed",
+		},
+		{
+			desc: "changing interleave parent is forbidden",
+			old: `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID);
+				CREATE TABLE Venues (VenueID INT64 NOT NULL) PRIMARY KEY (VenueID);
+				CREATE TABLE Albums (
+					SingerID INT64 NOT NULL,
+					AlbumID INT64 NOT NULL,
+				) PRIMARY KEY (SingerID, AlbumID), INTERLEAVE IN PARENT Singers`,
+			new: `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID);
+				CREATE TABLE Venues (VenueID INT64 NOT NULL) PRIMARY KEY (VenueID);
+				CREATE TABLE Albums (
+					SingerID INT64 NOT NULL,
+					AlbumID INT64 NOT NULL,
+				) PRIMARY KEY (SingerID, AlbumID), INTERLEAVE IN PARENT Venues`,
+			wantSub: "interleave parent cannot be changed",
+		},
+		{
+			desc: "restoring ON DELETE CASCADE is forbidden",
+			old: `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID);
+				CREATE TABLE Albums (
+					SingerID INT64 NOT NULL,
+					AlbumID INT64 NOT NULL,
+				) PRIMARY KEY (SingerID, AlbumID), INTERLEAVE IN PARENT Singers`,
+			new: `CREATE TABLE Singers (SingerID INT64 NOT NULL) PRIMARY KEY (SingerID);
+				CREATE TABLE Albums (
+					SingerID INT64 NOT NULL,
+					AlbumID INT64 NOT NULL,
+				) PRIMARY KEY (SingerID, AlbumID), INTERLEAVE IN PARENT Singers ON DELETE CASCADE`,
+			wantSub: "cannot be changed back to CASCADE",
+		},
+	}
+	for _, test := range tests {
+		_, err := DiffSQL(test.old, test.new)
+		if err == nil {
+			t.Errorf("%s: DiffSQL succeeded, want error containing %q", test.desc, test.wantSub)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.wantSub) {
+			t.Errorf("%s: DiffSQL error = %q, want substring %q", test.desc, err.Error(), test.wantSub)
+		}
+	}
+}
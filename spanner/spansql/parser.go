@@ -0,0 +1,1325 @@
+/*
+Copyright 2019 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spansql
+
+// This file holds the parser for the Cloud Spanner SQL dialect.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseQuery parses a query string.
+func ParseQuery(s string) (Query, error) {
+	p := newParser(s)
+	q, err := p.parseQuery()
+	if err != nil {
+		return Query{}, err
+	}
+	if !p.eof() {
+		return Query{}, p.errorf("unexpected trailing text %q", p.Rem())
+	}
+	return q, nil
+}
+
+// ParseDDL parses a sequence of DDL statements.
+func ParseDDL(s string) (DDL, error) {
+	p := newParser(s)
+	var ddl DDL
+	for {
+		if p.eof() {
+			break
+		}
+		stmt, err := p.parseDDLStmt()
+		if err != nil {
+			return DDL{}, err
+		}
+		ddl.List = append(ddl.List, stmt)
+		p.eat(";")
+	}
+	return ddl, nil
+}
+
+// parser holds the state of a single parse.
+type parser struct {
+	s    string // remaining input
+	orig string // original input, for error reporting
+}
+
+func newParser(s string) *parser {
+	return &parser{s: s, orig: s}
+}
+
+// Rem returns the unparsed remainder of the input.
+func (p *parser) Rem() string { return p.s }
+
+// position returns the 1-based line and column of the parser's current
+// read position within the original input, after skipping any leading
+// whitespace and comments so it points at the next real token.
+func (p *parser) position() Position {
+	p.skipSpaceAndComments()
+	consumed := len(p.orig) - len(p.s)
+	line, col := 1, 1
+	for _, r := range p.orig[:consumed] {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return Position{Line: line, Column: col}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("spansql: "+format+" [at %q]", append(args, p.s)...)
+}
+
+func (p *parser) advance(n int) { p.s = p.s[n:] }
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// skipSpaceAndComments advances past any leading whitespace and comments
+// (#..., --... and /*...*/ forms are all supported by Spanner's DDL/query
+// dialect).
+func (p *parser) skipSpaceAndComments() {
+	for {
+		i := 0
+		for i < len(p.s) && isSpace(p.s[i]) {
+			i++
+		}
+		p.s = p.s[i:]
+		switch {
+		case strings.HasPrefix(p.s, "#"), strings.HasPrefix(p.s, "--"):
+			if nl := strings.IndexByte(p.s, '\n'); nl >= 0 {
+				p.s = p.s[nl+1:]
+			} else {
+				p.s = ""
+			}
+		case strings.HasPrefix(p.s, "/*"):
+			if end := strings.Index(p.s, "*/"); end >= 0 {
+				p.s = p.s[end+2:]
+			} else {
+				p.s = ""
+			}
+		default:
+			return
+		}
+	}
+}
+
+// eof reports whether there is any input left, after skipping whitespace and comments.
+func (p *parser) eof() bool {
+	p.skipSpaceAndComments()
+	return p.s == ""
+}
+
+// eat consumes a literal token (punctuation such as "(" or ",") if it is next.
+func (p *parser) eat(tok string) bool {
+	p.skipSpaceAndComments()
+	if strings.HasPrefix(p.s, tok) {
+		p.advance(len(tok))
+		return true
+	}
+	return false
+}
+
+// eatKeyword consumes a case-insensitive keyword if it is next, respecting
+// word boundaries so e.g. "NOTFOO" doesn't match keyword "NOT".
+func (p *parser) eatKeyword(kw string) bool {
+	p.skipSpaceAndComments()
+	n := len(kw)
+	if len(p.s) < n || !strings.EqualFold(p.s[:n], kw) {
+		return false
+	}
+	if len(p.s) > n && isIdentByte(p.s[n]) {
+		return false
+	}
+	p.advance(n)
+	return true
+}
+
+// sniffTableConstraint reports whether the upcoming tokens form a table
+// constraint ("[CONSTRAINT <name>] FOREIGN KEY ...") without consuming them.
+// CONSTRAINT, FOREIGN, KEY and REFERENCES aren't reserved words in Spanner,
+// so this lookahead is what lets the column list in CREATE TABLE tell a
+// table constraint apart from an ordinary column definition that merely
+// starts with one of these idents. (ALTER TABLE ADD needs no lookahead: it
+// reads a column only after the COLUMN keyword.)
+func (p *parser) sniffTableConstraint() bool {
+	orig := p.s
+	defer func() { p.s = orig }()
+
+	if p.eatKeyword("CONSTRAINT") {
+		if _, err := p.parseIdent(); err != nil {
+			return false
+		}
+	}
+	return p.eatKeyword("FOREIGN") && p.eatKeyword("KEY")
+}
+
+func (p *parser) parseIdent() (string, error) {
+	p.skipSpaceAndComments()
+	if p.s == "" {
+		return "", p.errorf("expected identifier")
+	}
+	c := p.s[0]
+	if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+		return "", p.errorf("expected identifier")
+	}
+	i := 1
+	for i < len(p.s) && isIdentByte(p.s[i]) {
+		i++
+	}
+	id := p.s[:i]
+	p.advance(i)
+	return id, nil
+}
+
+func (p *parser) parseIdentList() ([]string, error) {
+	if !p.eat("(") {
+		return nil, p.errorf("expected (")
+	}
+	var list []string
+	for {
+		if p.eat(")") {
+			break
+		}
+		id, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, id)
+		if p.eat(",") {
+			continue
+		}
+		if p.eat(")") {
+			break
+		}
+		return nil, p.errorf("expected , or ) in identifier list")
+	}
+	return list, nil
+}
+
+func (p *parser) parseKeyPartList() ([]KeyPart, error) {
+	if !p.eat("(") {
+		return nil, p.errorf("expected (")
+	}
+	var list []KeyPart
+	for {
+		if p.eat(")") {
+			break
+		}
+		col, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		kp := KeyPart{Column: col}
+		if p.eatKeyword("DESC") {
+			kp.Desc = true
+		} else {
+			p.eatKeyword("ASC")
+		}
+		list = append(list, kp)
+		if p.eat(",") {
+			continue
+		}
+		if p.eat(")") {
+			break
+		}
+		return nil, p.errorf("expected , or ) in key part list")
+	}
+	return list, nil
+}
+
+// ---- DDL ----
+
+func (p *parser) parseDDLStmt() (DDLStmt, error) {
+	pos := p.position()
+	switch {
+	case p.eatKeyword("CREATE"):
+		switch {
+		case p.eatKeyword("TABLE"):
+			ct, err := p.parseCreateTable()
+			if err != nil {
+				return nil, err
+			}
+			ct.Position = pos
+			return ct, nil
+		case p.eatKeyword("INDEX"):
+			ci, err := p.parseCreateIndex()
+			if err != nil {
+				return nil, err
+			}
+			ci.Position = pos
+			return ci, nil
+		}
+		return nil, p.errorf("expected TABLE or INDEX after CREATE")
+	case p.eatKeyword("ALTER"):
+		if !p.eatKeyword("TABLE") {
+			return nil, p.errorf("expected TABLE after ALTER")
+		}
+		at, err := p.parseAlterTable()
+		if err != nil {
+			return nil, err
+		}
+		at.Position = pos
+		return at, nil
+	case p.eatKeyword("DROP"):
+		switch {
+		case p.eatKeyword("TABLE"):
+			name, err := p.parseIdent()
+			if err != nil {
+				return nil, err
+			}
+			return DropTable{Name: name, Position: pos}, nil
+		case p.eatKeyword("INDEX"):
+			name, err := p.parseIdent()
+			if err != nil {
+				return nil, err
+			}
+			return DropIndex{Name: name, Position: pos}, nil
+		}
+		return nil, p.errorf("expected TABLE or INDEX after DROP")
+	}
+	return nil, p.errorf("unknown DDL statement")
+}
+
+func (p *parser) parseCreateTable() (CreateTable, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return CreateTable{}, err
+	}
+	ct := CreateTable{Name: name}
+	if !p.eat("(") {
+		return CreateTable{}, p.errorf("expected ( after table name")
+	}
+	for {
+		if p.eat(")") {
+			break
+		}
+		if p.sniffTableConstraint() {
+			tc, err := p.parseTableConstraint()
+			if err != nil {
+				return CreateTable{}, err
+			}
+			ct.Constraints = append(ct.Constraints, tc)
+		} else {
+			cd, err := p.parseColumnDef()
+			if err != nil {
+				return CreateTable{}, err
+			}
+			ct.Columns = append(ct.Columns, cd)
+		}
+		if p.eat(",") {
+			continue
+		}
+		if p.eat(")") {
+			break
+		}
+		return CreateTable{}, p.errorf("expected , or ) in column list")
+	}
+	if !p.eatKeyword("PRIMARY") {
+		return CreateTable{}, p.errorf("expected PRIMARY KEY")
+	}
+	if !p.eatKeyword("KEY") {
+		return CreateTable{}, p.errorf("expected KEY after PRIMARY")
+	}
+	pk, err := p.parseKeyPartList()
+	if err != nil {
+		return CreateTable{}, err
+	}
+	ct.PrimaryKey = pk
+	if p.eat(",") {
+		iv, err := p.parseInterleave()
+		if err != nil {
+			return CreateTable{}, err
+		}
+		ct.Interleave = iv
+	}
+	return ct, nil
+}
+
+// parseInterleave parses an "INTERLEAVE IN PARENT <table> [ON DELETE ...]"
+// clause; the leading "," has already been consumed.
+func (p *parser) parseInterleave() (*Interleave, error) {
+	if !p.eatKeyword("INTERLEAVE") {
+		return nil, p.errorf("expected INTERLEAVE")
+	}
+	if !p.eatKeyword("IN") {
+		return nil, p.errorf("expected IN after INTERLEAVE")
+	}
+	if !p.eatKeyword("PARENT") {
+		return nil, p.errorf("expected PARENT after INTERLEAVE IN")
+	}
+	parent, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	iv := &Interleave{Parent: parent}
+	if p.eatKeyword("ON") {
+		if !p.eatKeyword("DELETE") {
+			return nil, p.errorf("expected DELETE after ON")
+		}
+		switch {
+		case p.eatKeyword("CASCADE"):
+			iv.OnDelete = OnDeleteCascade
+		case p.eatKeyword("NO"):
+			if !p.eatKeyword("ACTION") {
+				return nil, p.errorf("expected ACTION after NO")
+			}
+			iv.OnDelete = OnDeleteNoAction
+		default:
+			return nil, p.errorf("expected CASCADE or NO ACTION")
+		}
+	}
+	return iv, nil
+}
+
+func (p *parser) parseColumnDef() (ColumnDef, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return ColumnDef{}, err
+	}
+	typ, err := p.parseType()
+	if err != nil {
+		return ColumnDef{}, err
+	}
+	cd := ColumnDef{Name: name, Type: typ}
+	if p.eatKeyword("NOT") {
+		if !p.eatKeyword("NULL") {
+			return ColumnDef{}, p.errorf("expected NULL after NOT")
+		}
+		cd.NotNull = true
+	}
+	return cd, nil
+}
+
+func (p *parser) parseType() (Type, error) {
+	var t Type
+	if p.eatKeyword("ARRAY") {
+		if !p.eat("<") {
+			return Type{}, p.errorf("expected < after ARRAY")
+		}
+		inner, err := p.parseType()
+		if err != nil {
+			return Type{}, err
+		}
+		if inner.Array {
+			return Type{}, p.errorf("ARRAY of ARRAY is not supported")
+		}
+		if !p.eat(">") {
+			return Type{}, p.errorf("expected > to close ARRAY<...>")
+		}
+		inner.Array = true
+		return inner, nil
+	}
+	switch {
+	case p.eatKeyword("BOOL"):
+		t.Base = Bool
+	case p.eatKeyword("INT64"):
+		t.Base = Int64
+	case p.eatKeyword("FLOAT64"):
+		t.Base = Float64
+	case p.eatKeyword("TIMESTAMP"):
+		t.Base = Timestamp
+	case p.eatKeyword("DATE"):
+		t.Base = Date
+	case p.eatKeyword("STRING"):
+		t.Base = String
+		n, err := p.parseTypeLen()
+		if err != nil {
+			return Type{}, err
+		}
+		t.Len = n
+	case p.eatKeyword("BYTES"):
+		t.Base = Bytes
+		n, err := p.parseTypeLen()
+		if err != nil {
+			return Type{}, err
+		}
+		t.Len = n
+	default:
+		return Type{}, p.errorf("unknown type")
+	}
+	return t, nil
+}
+
+func (p *parser) parseTypeLen() (int64, error) {
+	if !p.eat("(") {
+		return 0, p.errorf("expected ( for type length")
+	}
+	var n int64
+	if p.eatKeyword("MAX") {
+		n = MaxLen
+	} else {
+		e, err := p.parseLiteralNumber()
+		if err != nil {
+			return 0, err
+		}
+		lit, ok := e.(IntegerLiteral)
+		if !ok {
+			return 0, p.errorf("expected integer length")
+		}
+		n = int64(lit)
+	}
+	if !p.eat(")") {
+		return 0, p.errorf("expected ) after type length")
+	}
+	return n, nil
+}
+
+func (p *parser) parseCreateIndex() (CreateIndex, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return CreateIndex{}, err
+	}
+	if !p.eatKeyword("ON") {
+		return CreateIndex{}, p.errorf("expected ON")
+	}
+	table, err := p.parseIdent()
+	if err != nil {
+		return CreateIndex{}, err
+	}
+	cols, err := p.parseKeyPartList()
+	if err != nil {
+		return CreateIndex{}, err
+	}
+	return CreateIndex{Name: name, Table: table, Columns: cols}, nil
+}
+
+func (p *parser) parseAlterTable() (AlterTable, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return AlterTable{}, err
+	}
+	at := AlterTable{Name: name}
+	switch {
+	case p.eatKeyword("ADD"):
+		if p.eatKeyword("COLUMN") {
+			cd, err := p.parseColumnDef()
+			if err != nil {
+				return AlterTable{}, err
+			}
+			at.Alteration = AddColumn{Def: cd}
+			return at, nil
+		}
+		tc, err := p.parseTableConstraint()
+		if err != nil {
+			return AlterTable{}, err
+		}
+		at.Alteration = AddConstraint{Constraint: tc}
+		return at, nil
+	case p.eatKeyword("DROP"):
+		if p.eatKeyword("COLUMN") {
+			n, err := p.parseIdent()
+			if err != nil {
+				return AlterTable{}, err
+			}
+			at.Alteration = DropColumn{Name: n}
+			return at, nil
+		}
+		if p.eatKeyword("CONSTRAINT") {
+			n, err := p.parseIdent()
+			if err != nil {
+				return AlterTable{}, err
+			}
+			at.Alteration = DropConstraint{Name: n}
+			return at, nil
+		}
+		return AlterTable{}, p.errorf("expected COLUMN or CONSTRAINT after DROP")
+	case p.eatKeyword("ALTER"):
+		if !p.eatKeyword("COLUMN") {
+			return AlterTable{}, p.errorf("expected COLUMN after ALTER")
+		}
+		n, err := p.parseIdent()
+		if err != nil {
+			return AlterTable{}, err
+		}
+		typ, err := p.parseType()
+		if err != nil {
+			return AlterTable{}, err
+		}
+		ac := AlterColumn{Name: n, Type: typ}
+		if p.eatKeyword("NOT") {
+			if !p.eatKeyword("NULL") {
+				return AlterTable{}, p.errorf("expected NULL after NOT")
+			}
+			ac.NotNull = true
+		}
+		at.Alteration = ac
+		return at, nil
+	case p.eatKeyword("RENAME"):
+		if !p.eatKeyword("COLUMN") {
+			return AlterTable{}, p.errorf("expected COLUMN after RENAME")
+		}
+		from, err := p.parseIdent()
+		if err != nil {
+			return AlterTable{}, err
+		}
+		if !p.eatKeyword("TO") {
+			return AlterTable{}, p.errorf("expected TO after RENAME COLUMN %s", from)
+		}
+		to, err := p.parseIdent()
+		if err != nil {
+			return AlterTable{}, err
+		}
+		at.Alteration = RenameColumn{From: from, To: to}
+		return at, nil
+	case p.eatKeyword("SET"):
+		if !p.eatKeyword("ON") {
+			return AlterTable{}, p.errorf("expected ON after SET")
+		}
+		if !p.eatKeyword("DELETE") {
+			return AlterTable{}, p.errorf("expected DELETE after ON")
+		}
+		if !p.eatKeyword("NO") {
+			return AlterTable{}, p.errorf("expected NO ACTION")
+		}
+		if !p.eatKeyword("ACTION") {
+			return AlterTable{}, p.errorf("expected ACTION after NO")
+		}
+		at.Alteration = NoActionOnDelete
+		return at, nil
+	}
+	return AlterTable{}, p.errorf("unknown ALTER TABLE alteration")
+}
+
+// parseTableConstraint parses a table-level constraint:
+// [CONSTRAINT <name>] FOREIGN KEY (cols) REFERENCES Table (cols) [ON DELETE ...]
+func (p *parser) parseTableConstraint() (TableConstraint, error) {
+	var tc TableConstraint
+	if p.eatKeyword("CONSTRAINT") {
+		name, err := p.parseIdent()
+		if err != nil {
+			return TableConstraint{}, err
+		}
+		tc.Name = name
+	}
+	fk, err := p.parseForeignKey()
+	if err != nil {
+		return TableConstraint{}, err
+	}
+	tc.ForeignKey = fk
+	return tc, nil
+}
+
+func (p *parser) parseForeignKey() (ForeignKey, error) {
+	if !p.eatKeyword("FOREIGN") {
+		return ForeignKey{}, p.errorf("expected FOREIGN")
+	}
+	if !p.eatKeyword("KEY") {
+		return ForeignKey{}, p.errorf("expected KEY after FOREIGN")
+	}
+	cols, err := p.parseIdentList()
+	if err != nil {
+		return ForeignKey{}, err
+	}
+	if !p.eatKeyword("REFERENCES") {
+		return ForeignKey{}, p.errorf("expected REFERENCES")
+	}
+	refTable, err := p.parseIdent()
+	if err != nil {
+		return ForeignKey{}, err
+	}
+	refCols, err := p.parseIdentList()
+	if err != nil {
+		return ForeignKey{}, err
+	}
+	fk := ForeignKey{Columns: cols, RefTable: refTable, RefColumns: refCols}
+	if p.eatKeyword("ON") {
+		if !p.eatKeyword("DELETE") {
+			return ForeignKey{}, p.errorf("expected DELETE after ON")
+		}
+		switch {
+		case p.eatKeyword("CASCADE"):
+			fk.OnDelete = OnDeleteCascade
+		case p.eatKeyword("NO"):
+			if !p.eatKeyword("ACTION") {
+				return ForeignKey{}, p.errorf("expected ACTION after NO")
+			}
+			fk.OnDelete = OnDeleteNoAction
+		default:
+			return ForeignKey{}, p.errorf("expected CASCADE or NO ACTION")
+		}
+	}
+	return fk, nil
+}
+
+// ---- Query ----
+
+func (p *parser) parseQuery() (Query, error) {
+	var q Query
+	sel, err := p.parseSelect()
+	if err != nil {
+		return Query{}, err
+	}
+	q.Select = sel
+	if p.eatKeyword("ORDER") {
+		if !p.eatKeyword("BY") {
+			return Query{}, p.errorf("expected BY after ORDER")
+		}
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return Query{}, err
+			}
+			o := Order{Expr: e}
+			if p.eatKeyword("DESC") {
+				o.Desc = true
+			} else {
+				p.eatKeyword("ASC")
+			}
+			q.Order = append(q.Order, o)
+			if !p.eat(",") {
+				break
+			}
+		}
+	}
+	if p.eatKeyword("LIMIT") {
+		e, err := p.parseExpr()
+		if err != nil {
+			return Query{}, err
+		}
+		q.Limit = e
+	}
+	return q, nil
+}
+
+func (p *parser) parseSelect() (Select, error) {
+	if !p.eatKeyword("SELECT") {
+		return Select{}, p.errorf("expected SELECT")
+	}
+	var sel Select
+	for {
+		p.skipSpaceAndComments()
+		var e Expr
+		var err error
+		if strings.HasPrefix(p.s, "*") {
+			p.advance(1)
+			e = Star
+		} else {
+			e, err = p.parseExpr()
+			if err != nil {
+				return Select{}, err
+			}
+		}
+		sel.List = append(sel.List, e)
+		if !p.eat(",") {
+			break
+		}
+	}
+	if p.eatKeyword("FROM") {
+		for {
+			sf, err := p.parseSelectFrom()
+			if err != nil {
+				return Select{}, err
+			}
+			sel.From = append(sel.From, sf)
+			if !p.eat(",") {
+				break
+			}
+		}
+	}
+	if p.eatKeyword("WHERE") {
+		e, err := p.parseExpr()
+		if err != nil {
+			return Select{}, err
+		}
+		sel.Where = e
+	}
+	if p.eatKeyword("GROUP") {
+		if !p.eatKeyword("BY") {
+			return Select{}, p.errorf("expected BY after GROUP")
+		}
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return Select{}, err
+			}
+			sel.GroupBy = append(sel.GroupBy, e)
+			if !p.eat(",") {
+				break
+			}
+		}
+	}
+	if p.eatKeyword("HAVING") {
+		e, err := p.parseExpr()
+		if err != nil {
+			return Select{}, err
+		}
+		sel.Having = e
+	}
+	return sel, nil
+}
+
+// parseSelectFrom parses a single FROM clause item, including any chain of
+// JOINs applied to it; the result is left-associative, e.g. "A JOIN B JOIN C"
+// parses as SelectFromJoin{LHS: SelectFromJoin{LHS: A, RHS: B}, RHS: C}.
+func (p *parser) parseSelectFrom() (SelectFrom, error) {
+	lhs, err := p.parseFromItem()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		jt, ok, err := p.parseJoinType()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return lhs, nil
+		}
+		rhs, err := p.parseFromItem()
+		if err != nil {
+			return nil, err
+		}
+		join := SelectFromJoin{Type: jt, LHS: lhs, RHS: rhs}
+		if jt != CrossJoin {
+			if p.eatKeyword("ON") {
+				e, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				join.On = e
+			} else if p.eatKeyword("USING") {
+				cols, err := p.parseIdentList()
+				if err != nil {
+					return nil, err
+				}
+				join.Using = cols
+			}
+		}
+		lhs = join
+	}
+}
+
+// parseJoinType attempts to consume a join keyword sequence
+// (e.g. "LEFT OUTER JOIN", "JOIN", "CROSS JOIN"). It reports ok == false,
+// restoring any partially consumed input, if none is present.
+func (p *parser) parseJoinType() (jt JoinType, ok bool, err error) {
+	orig := p.s
+	switch {
+	case p.eatKeyword("INNER"):
+		if !p.eatKeyword("JOIN") {
+			p.s = orig
+			return 0, false, nil
+		}
+		return InnerJoin, true, nil
+	case p.eatKeyword("LEFT"):
+		p.eatKeyword("OUTER")
+		if !p.eatKeyword("JOIN") {
+			p.s = orig
+			return 0, false, nil
+		}
+		return LeftJoin, true, nil
+	case p.eatKeyword("RIGHT"):
+		p.eatKeyword("OUTER")
+		if !p.eatKeyword("JOIN") {
+			p.s = orig
+			return 0, false, nil
+		}
+		return RightJoin, true, nil
+	case p.eatKeyword("FULL"):
+		p.eatKeyword("OUTER")
+		if !p.eatKeyword("JOIN") {
+			p.s = orig
+			return 0, false, nil
+		}
+		return FullJoin, true, nil
+	case p.eatKeyword("CROSS"):
+		if !p.eatKeyword("JOIN") {
+			p.s = orig
+			return 0, false, nil
+		}
+		return CrossJoin, true, nil
+	case p.eatKeyword("JOIN"):
+		return InnerJoin, true, nil
+	}
+	return 0, false, nil
+}
+
+// parseFromItem parses a single FROM clause source: a table name, an
+// UNNEST(...), a parenthesized subquery, or a parenthesized join chain.
+func (p *parser) parseFromItem() (SelectFrom, error) {
+	if p.eatKeyword("UNNEST") {
+		if !p.eat("(") {
+			return nil, p.errorf("expected ( after UNNEST")
+		}
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.eat(")") {
+			return nil, p.errorf("expected ) to close UNNEST(...)")
+		}
+		u := SelectFromUnnest{Expr: e, Alias: p.parseOptionalAlias()}
+		if p.eatKeyword("WITH") {
+			if !p.eatKeyword("OFFSET") {
+				return nil, p.errorf("expected OFFSET after WITH")
+			}
+			u.WithOffset = true
+			u.WithOffsetAlias = p.parseOptionalAlias()
+		}
+		return u, nil
+	}
+	if p.eat("(") {
+		if p.peekKeyword("SELECT") {
+			q, err := p.parseQuery()
+			if err != nil {
+				return nil, err
+			}
+			if !p.eat(")") {
+				return nil, p.errorf("expected ) to close subquery")
+			}
+			return SelectFromSubquery{Query: q, Alias: p.parseOptionalAlias()}, nil
+		}
+		inner, err := p.parseSelectFrom()
+		if err != nil {
+			return nil, err
+		}
+		if !p.eat(")") {
+			return nil, p.errorf("expected ) to close parenthesized join")
+		}
+		return inner, nil
+	}
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	return SelectFromTable{Table: name, Alias: p.parseOptionalAlias()}, nil
+}
+
+// parseOptionalAlias parses an "AS alias" clause if present, returning "" otherwise.
+func (p *parser) parseOptionalAlias() string {
+	if !p.eatKeyword("AS") {
+		return ""
+	}
+	id, err := p.parseIdent()
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// peekKeyword reports whether kw is next, without consuming any input.
+func (p *parser) peekKeyword(kw string) bool {
+	orig := p.s
+	ok := p.eatKeyword(kw)
+	p.s = orig
+	return ok
+}
+
+// ---- Expressions ----
+
+// parseExpr parses a full expression, the entry point for expression parsing.
+func (p *parser) parseExpr() (Expr, error) {
+	return p.parseOr()
+}
+
+func isStringLit(e Expr) bool {
+	_, ok := e.(StringLiteral)
+	return ok
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.eatKeyword("OR") {
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if isStringLit(lhs) || isStringLit(rhs) {
+			return nil, p.errorf("logical operation on string literal")
+		}
+		lhs = LogicalOp{LHS: lhs, Op: Or, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.eatKeyword("AND") {
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if isStringLit(lhs) || isStringLit(rhs) {
+			return nil, p.errorf("logical operation on string literal")
+		}
+		lhs = LogicalOp{LHS: lhs, Op: And, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.eatKeyword("NOT") {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return LogicalOp{Op: Not, RHS: e}, nil
+	}
+	lhs, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseComparisonOrIs(lhs)
+}
+
+func (p *parser) parseComparisonOrIs(lhs Expr) (Expr, error) {
+	if p.eatKeyword("IS") {
+		neg := p.eatKeyword("NOT")
+		rhs, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return IsOp{LHS: lhs, Neg: neg, RHS: rhs}, nil
+	}
+	neg := p.eatKeyword("NOT")
+	if p.eatKeyword("LIKE") {
+		rhs, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		op := Like
+		if neg {
+			op = NotLike
+		}
+		return ComparisonOp{LHS: lhs, Op: op, RHS: rhs}, nil
+	}
+	if p.eatKeyword("IN") {
+		return p.parseInOp(lhs, neg)
+	}
+	if neg {
+		return nil, p.errorf("expected LIKE or IN after NOT")
+	}
+	syms := []struct {
+		tok string
+		op  ComparisonOperator
+	}{
+		{"<=", Le}, {">=", Ge}, {"!=", Ne}, {"<>", Ne}, {"<", Lt}, {">", Gt}, {"=", Eq},
+	}
+	for _, sym := range syms {
+		if p.eat(sym.tok) {
+			rhs, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return ComparisonOp{LHS: lhs, Op: sym.op, RHS: rhs}, nil
+		}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	p.skipSpaceAndComments()
+	if p.s == "" {
+		return nil, p.errorf("expected expression")
+	}
+	if p.eatKeyword("EXISTS") {
+		if !p.eat("(") {
+			return nil, p.errorf("expected ( after EXISTS")
+		}
+		q, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		if !p.eat(")") {
+			return nil, p.errorf("expected ) to close EXISTS(...)")
+		}
+		return ExistsSubquery{Query: q}, nil
+	}
+	c := p.s[0]
+	switch {
+	case c == '"' || c == '\'':
+		return p.parseStringLiteral()
+	case c == '@':
+		return p.parseParam()
+	case c == '(':
+		p.advance(1)
+		if p.peekKeyword("SELECT") {
+			q, err := p.parseQuery()
+			if err != nil {
+				return nil, err
+			}
+			if !p.eat(")") {
+				return nil, p.errorf("expected ) to close scalar subquery")
+			}
+			return ScalarSubquery{Query: q}, nil
+		}
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.eat(")") {
+			return nil, p.errorf("expected ) to close parenthesized expression")
+		}
+		return e, nil
+	case c == '-' || c == '.' || isDigit(c):
+		return p.parseLiteralNumber()
+	default:
+		id, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		if p.eat("(") {
+			return p.parseFuncCallArgs(id)
+		}
+		for p.eat(".") {
+			next, err := p.parseIdent()
+			if err != nil {
+				return nil, err
+			}
+			id += "." + next
+		}
+		switch {
+		case strings.EqualFold(id, "TRUE"):
+			return True, nil
+		case strings.EqualFold(id, "FALSE"):
+			return False, nil
+		case strings.EqualFold(id, "NULL"):
+			return Null, nil
+		}
+		return ID(id), nil
+	}
+}
+
+// parseInOp parses the remainder of an "X [NOT] IN (...)" expression, where
+// the opening keyword(s) and lhs have already been consumed.
+func (p *parser) parseInOp(lhs Expr, neg bool) (Expr, error) {
+	if !p.eat("(") {
+		return nil, p.errorf("expected ( after IN")
+	}
+	if p.peekKeyword("SELECT") {
+		q, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		if !p.eat(")") {
+			return nil, p.errorf("expected ) to close IN (SELECT ...)")
+		}
+		return InOp{LHS: lhs, Neg: neg, Query: &q}, nil
+	}
+	var vals []Expr
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, e)
+		if p.eat(",") {
+			continue
+		}
+		break
+	}
+	if !p.eat(")") {
+		return nil, p.errorf("expected ) to close IN (...)")
+	}
+	return InOp{LHS: lhs, Neg: neg, Values: vals}, nil
+}
+
+// parseFuncCallArgs parses the argument list and closing ")" of a function
+// call whose name and opening "(" have already been consumed.
+func (p *parser) parseFuncCallArgs(name string) (Expr, error) {
+	fc := FuncCall{Name: name}
+	if p.eat(")") {
+		return fc, nil
+	}
+	if p.eat("*") {
+		// COUNT(*) and friends.
+		fc.Args = []Expr{Star}
+		if !p.eat(")") {
+			return nil, p.errorf("expected ) after *")
+		}
+		return fc, nil
+	}
+	if p.eatKeyword("DISTINCT") {
+		fc.Distinct = true
+	}
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		fc.Args = append(fc.Args, e)
+		if p.eat(",") {
+			continue
+		}
+		break
+	}
+	if !p.eat(")") {
+		return nil, p.errorf("expected ) to close function call")
+	}
+	return fc, nil
+}
+
+func (p *parser) parseLiteralNumber() (Expr, error) {
+	p.skipSpaceAndComments()
+	s := p.s
+	i := 0
+	neg := false
+	if i < len(s) && s[i] == '-' {
+		neg = true
+		i++
+	}
+	start := i
+
+	if i+1 < len(s) && s[i] == '0' && (s[i+1] == 'x' || s[i+1] == 'X') {
+		j := i + 2
+		k := j
+		for k < len(s) && isHexDigit(s[k]) {
+			k++
+		}
+		if k == j {
+			return nil, p.errorf("malformed hex integer literal")
+		}
+		v, err := strconv.ParseInt(s[j:k], 16, 64)
+		if err != nil {
+			return nil, p.errorf("bad hex integer literal: %v", err)
+		}
+		if neg {
+			v = -v
+		}
+		p.advance(k)
+		return IntegerLiteral(v), nil
+	}
+
+	j := i
+	for j < len(s) && isDigit(s[j]) {
+		j++
+	}
+	isFloat := false
+	if j < len(s) && s[j] == '.' {
+		isFloat = true
+		j++
+		for j < len(s) && isDigit(s[j]) {
+			j++
+		}
+	}
+	if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+		k := j + 1
+		if k < len(s) && (s[k] == '+' || s[k] == '-') {
+			k++
+		}
+		d := k
+		for d < len(s) && isDigit(s[d]) {
+			d++
+		}
+		if d > k {
+			isFloat = true
+			j = d
+		}
+	}
+	if j == start {
+		return nil, p.errorf("expected number")
+	}
+	lit := s[start:j]
+	p.advance(j)
+	if isFloat {
+		v, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, p.errorf("bad float literal: %v", err)
+		}
+		if neg {
+			v = -v
+		}
+		return FloatLiteral(v), nil
+	}
+	v, err := strconv.ParseInt(lit, 10, 64)
+	if err != nil {
+		return nil, p.errorf("bad integer literal: %v", err)
+	}
+	if neg {
+		v = -v
+	}
+	return IntegerLiteral(v), nil
+}
+
+func (p *parser) parseStringLiteral() (StringLiteral, error) {
+	p.skipSpaceAndComments()
+	if p.s == "" {
+		return "", p.errorf("expected string literal")
+	}
+	quote := p.s[0]
+	if quote != '"' && quote != '\'' {
+		return "", p.errorf("expected string literal")
+	}
+	var sb strings.Builder
+	i := 1
+	for {
+		if i >= len(p.s) {
+			return "", p.errorf("unterminated string literal")
+		}
+		c := p.s[i]
+		if c == quote {
+			i++
+			break
+		}
+		if c == '\\' {
+			i++
+			if i >= len(p.s) {
+				return "", p.errorf("unterminated string literal")
+			}
+			switch p.s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			default:
+				sb.WriteByte(p.s[i])
+			}
+			i++
+			continue
+		}
+		sb.WriteByte(c)
+		i++
+	}
+	p.advance(i)
+	return StringLiteral(sb.String()), nil
+}
+
+func (p *parser) parseParam() (Param, error) {
+	p.skipSpaceAndComments()
+	if !strings.HasPrefix(p.s, "@") {
+		return "", p.errorf("expected parameter")
+	}
+	p.advance(1)
+	id, err := p.parseIdent()
+	if err != nil {
+		return "", err
+	}
+	return Param(id), nil
+}
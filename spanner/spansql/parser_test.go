@@ -31,7 +31,7 @@ func TestParseQuery(t *testing.T) {
 			Query{
 				Select: Select{
 					List: []Expr{ID("Alias")},
-					From: []SelectFrom{{
+					From: []SelectFrom{SelectFromTable{
 						Table: "Characters",
 					}},
 					Where: LogicalOp{
@@ -167,19 +167,21 @@ func TestParseDDL(t *testing.T) {
 					{Column: "System"},
 					{Column: "RepoPath"},
 				},
+				Position: Position{Line: 1, Column: 1},
 			},
 			CreateIndex{
-				Name:    "MyFirstIndex",
-				Table:   "FooBar",
-				Columns: []KeyPart{{Column: "Count", Desc: true}},
+				Name:     "MyFirstIndex",
+				Table:    "FooBar",
+				Columns:  []KeyPart{{Column: "Count", Desc: true}},
+				Position: Position{Line: 7, Column: 3},
 			},
 			AlterTable{Name: "FooBar", Alteration: AddColumn{
 				Def: ColumnDef{Name: "TZ", Type: Type{Base: Bytes, Len: 20}},
-			}},
-			AlterTable{Name: "FooBar", Alteration: DropColumn{Name: "TZ"}},
-			AlterTable{Name: "FooBar", Alteration: NoActionOnDelete},
-			DropIndex{Name: "MyFirstIndex"},
-			DropTable{Name: "FooBar"},
+			}, Position: Position{Line: 11, Column: 3}},
+			AlterTable{Name: "FooBar", Alteration: DropColumn{Name: "TZ"}, Position: Position{Line: 12, Column: 3}},
+			AlterTable{Name: "FooBar", Alteration: NoActionOnDelete, Position: Position{Line: 13, Column: 3}},
+			DropIndex{Name: "MyFirstIndex", Position: Position{Line: 15, Column: 3}},
+			DropTable{Name: "FooBar", Position: Position{Line: 16, Column: 3}},
 			CreateTable{
 				Name: "NonScalars",
 				Columns: []ColumnDef{
@@ -188,13 +190,14 @@ func TestParseDDL(t *testing.T) {
 					{Name: "Names", Type: Type{Array: true, Base: String, Len: MaxLen}},
 				},
 				PrimaryKey: []KeyPart{{Column: "Dummy"}},
+				Position:   Position{Line: 18, Column: 3},
 			},
 		}}},
 		// No trailing comma:
 		{`ALTER TABLE T ADD COLUMN C2 INT64`, DDL{List: []DDLStmt{
 			AlterTable{Name: "T", Alteration: AddColumn{
 				Def: ColumnDef{Name: "C2", Type: Type{Base: Int64}},
-			}},
+			}, Position: Position{Line: 1, Column: 1}},
 		}}},
 	}
 	for _, test := range tests {
@@ -230,4 +233,244 @@ func TestParseFailures(t *testing.T) {
 			t.Errorf("%s: parsing [%s] succeeded, should have failed", test.desc, test.in)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestParseQueryGroupByHaving(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Query
+	}{
+		{`SELECT Country, COUNT(*) FROM Singers GROUP BY Country HAVING COUNT(*) > 1`,
+			Query{
+				Select: Select{
+					List: []Expr{
+						ID("Country"),
+						FuncCall{Name: "COUNT", Args: []Expr{Star}},
+					},
+					From:    []SelectFrom{SelectFromTable{Table: "Singers"}},
+					GroupBy: []Expr{ID("Country")},
+					Having: ComparisonOp{
+						LHS: FuncCall{Name: "COUNT", Args: []Expr{Star}},
+						Op:  Gt,
+						RHS: IntegerLiteral(1),
+					},
+				},
+			},
+		},
+		{`SELECT SingerID, SUM(Amount), AVG(Amount), MIN(Amount), MAX(Amount), COUNT(DISTINCT Amount) FROM Sales GROUP BY SingerID`,
+			Query{
+				Select: Select{
+					List: []Expr{
+						ID("SingerID"),
+						FuncCall{Name: "SUM", Args: []Expr{ID("Amount")}},
+						FuncCall{Name: "AVG", Args: []Expr{ID("Amount")}},
+						FuncCall{Name: "MIN", Args: []Expr{ID("Amount")}},
+						FuncCall{Name: "MAX", Args: []Expr{ID("Amount")}},
+						FuncCall{Name: "COUNT", Args: []Expr{ID("Amount")}, Distinct: true},
+					},
+					From:    []SelectFrom{SelectFromTable{Table: "Sales"}},
+					GroupBy: []Expr{ID("SingerID")},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		got, err := ParseQuery(test.in)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseQuery(%q) incorrect.\n got %#v\nwant %#v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseQueryJoinsAndSubqueries(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Query
+	}{
+		// Chained joins are left-associative.
+		{`SELECT 1 FROM A INNER JOIN B ON A.X = B.X LEFT OUTER JOIN C USING (Y)`,
+			Query{
+				Select: Select{
+					List: []Expr{IntegerLiteral(1)},
+					From: []SelectFrom{
+						SelectFromJoin{
+							Type: LeftJoin,
+							LHS: SelectFromJoin{
+								Type: InnerJoin,
+								LHS:  SelectFromTable{Table: "A"},
+								RHS:  SelectFromTable{Table: "B"},
+								On:   ComparisonOp{LHS: ID("A.X"), Op: Eq, RHS: ID("B.X")},
+							},
+							RHS:   SelectFromTable{Table: "C"},
+							Using: []string{"Y"},
+						},
+					},
+				},
+			},
+		},
+		// UNNEST with offset.
+		{`SELECT 1 FROM UNNEST(Ids) AS Id WITH OFFSET AS Pos`,
+			Query{
+				Select: Select{
+					List: []Expr{IntegerLiteral(1)},
+					From: []SelectFrom{
+						SelectFromUnnest{
+							Expr:            ID("Ids"),
+							Alias:           "Id",
+							WithOffset:      true,
+							WithOffsetAlias: "Pos",
+						},
+					},
+				},
+			},
+		},
+		// Subquery in FROM, and a correlated IN (SELECT ...) subquery in the WHERE clause.
+		{`SELECT 1 FROM (SELECT SingerID FROM Singers) AS S WHERE S.SingerID IN (SELECT SingerID FROM Albums WHERE Albums.SingerID = S.SingerID)`,
+			Query{
+				Select: Select{
+					List: []Expr{IntegerLiteral(1)},
+					From: []SelectFrom{
+						SelectFromSubquery{
+							Query: Query{
+								Select: Select{
+									List: []Expr{ID("SingerID")},
+									From: []SelectFrom{SelectFromTable{Table: "Singers"}},
+								},
+							},
+							Alias: "S",
+						},
+					},
+					Where: InOp{
+						LHS: ID("S.SingerID"),
+						Query: &Query{
+							Select: Select{
+								List: []Expr{ID("SingerID")},
+								From: []SelectFrom{SelectFromTable{Table: "Albums"}},
+								Where: ComparisonOp{
+									LHS: ID("Albums.SingerID"),
+									Op:  Eq,
+									RHS: ID("S.SingerID"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		got, err := ParseQuery(test.in)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseQuery(%q) incorrect.\n got %#v\nwant %#v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseDDLForeignKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want DDL
+	}{
+		{`CREATE TABLE Orders (
+			OrderID INT64 NOT NULL,
+			CustomerID INT64 NOT NULL,
+			CONSTRAINT FK_Customer FOREIGN KEY (CustomerID) REFERENCES Customers (CustomerID),
+		) PRIMARY KEY (OrderID);`, DDL{List: []DDLStmt{
+			CreateTable{
+				Name: "Orders",
+				Columns: []ColumnDef{
+					{Name: "OrderID", Type: Type{Base: Int64}, NotNull: true},
+					{Name: "CustomerID", Type: Type{Base: Int64}, NotNull: true},
+				},
+				Constraints: []TableConstraint{
+					{
+						Name: "FK_Customer",
+						ForeignKey: ForeignKey{
+							Columns:    []string{"CustomerID"},
+							RefTable:   "Customers",
+							RefColumns: []string{"CustomerID"},
+						},
+					},
+				},
+				PrimaryKey: []KeyPart{{Column: "OrderID"}},
+				Position:   Position{Line: 1, Column: 1},
+			},
+		}}},
+		// Unnamed constraint, ON DELETE CASCADE.
+		{`CREATE TABLE Items (
+			ItemID INT64 NOT NULL,
+			OrderID INT64 NOT NULL,
+			FOREIGN KEY (OrderID) REFERENCES Orders (OrderID) ON DELETE CASCADE,
+		) PRIMARY KEY (ItemID);`, DDL{List: []DDLStmt{
+			CreateTable{
+				Name: "Items",
+				Columns: []ColumnDef{
+					{Name: "ItemID", Type: Type{Base: Int64}, NotNull: true},
+					{Name: "OrderID", Type: Type{Base: Int64}, NotNull: true},
+				},
+				Constraints: []TableConstraint{
+					{
+						ForeignKey: ForeignKey{
+							Columns:    []string{"OrderID"},
+							RefTable:   "Orders",
+							RefColumns: []string{"OrderID"},
+							OnDelete:   OnDeleteCascade,
+						},
+					},
+				},
+				PrimaryKey: []KeyPart{{Column: "ItemID"}},
+				Position:   Position{Line: 1, Column: 1},
+			},
+		}}},
+		{`ALTER TABLE Orders ADD CONSTRAINT FK_Customer FOREIGN KEY (CustomerID) REFERENCES Customers (CustomerID) ON DELETE NO ACTION`, DDL{List: []DDLStmt{
+			AlterTable{Name: "Orders", Alteration: AddConstraint{
+				Constraint: TableConstraint{
+					Name: "FK_Customer",
+					ForeignKey: ForeignKey{
+						Columns:    []string{"CustomerID"},
+						RefTable:   "Customers",
+						RefColumns: []string{"CustomerID"},
+						OnDelete:   OnDeleteNoAction,
+					},
+				},
+			}, Position: Position{Line: 1, Column: 1}},
+		}}},
+		{`ALTER TABLE Orders DROP CONSTRAINT FK_Customer`, DDL{List: []DDLStmt{
+			AlterTable{Name: "Orders", Alteration: DropConstraint{Name: "FK_Customer"}, Position: Position{Line: 1, Column: 1}},
+		}}},
+		// Columns named after constraint keywords aren't reserved words, so
+		// they must still parse as ordinary column definitions.
+		{`CREATE TABLE T (
+			Foreign INT64 NOT NULL,
+			Constraint INT64 NOT NULL,
+		) PRIMARY KEY (Foreign);`, DDL{List: []DDLStmt{
+			CreateTable{
+				Name: "T",
+				Columns: []ColumnDef{
+					{Name: "Foreign", Type: Type{Base: Int64}, NotNull: true},
+					{Name: "Constraint", Type: Type{Base: Int64}, NotNull: true},
+				},
+				PrimaryKey: []KeyPart{{Column: "Foreign"}},
+				Position:   Position{Line: 1, Column: 1},
+			},
+		}}},
+	}
+	for _, test := range tests {
+		got, err := ParseDDL(test.in)
+		if err != nil {
+			t.Errorf("ParseDDL(%q): %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseDDL(%q) incorrect.\n got %v\nwant %v", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,466 @@
+/*
+Copyright 2019 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spansql
+
+// This file holds serialization of the AST types back into SQL text, the
+// inverse of the parsing done in parser.go.
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SQL returns the DDL statements as SQL text.
+func (d DDL) SQL() string {
+	var sb strings.Builder
+	for i, stmt := range d.List {
+		if i > 0 {
+			sb.WriteString(";\n")
+		}
+		sb.WriteString(stmt.SQL())
+	}
+	return sb.String()
+}
+
+func (ct CreateTable) SQL() string {
+	var sb strings.Builder
+	sb.WriteString("CREATE TABLE ")
+	sb.WriteString(quoteIdent(ct.Name))
+	sb.WriteString(" (")
+	var items []string
+	for _, c := range ct.Columns {
+		items = append(items, c.SQL())
+	}
+	for _, c := range ct.Constraints {
+		items = append(items, c.SQL())
+	}
+	sb.WriteString(strings.Join(items, ", "))
+	sb.WriteString(") PRIMARY KEY (")
+	sb.WriteString(keyPartListSQL(ct.PrimaryKey))
+	sb.WriteString(")")
+	if ct.Interleave != nil {
+		sb.WriteString(", ")
+		sb.WriteString(ct.Interleave.SQL())
+	}
+	return sb.String()
+}
+
+func (iv Interleave) SQL() string {
+	s := "INTERLEAVE IN PARENT " + quoteIdent(iv.Parent)
+	if iv.OnDelete == OnDeleteCascade {
+		s += " ON DELETE CASCADE"
+	}
+	return s
+}
+
+func (ci CreateIndex) SQL() string {
+	return "CREATE INDEX " + quoteIdent(ci.Name) + " ON " + quoteIdent(ci.Table) +
+		" (" + keyPartListSQL(ci.Columns) + ")"
+}
+
+func (at AlterTable) SQL() string {
+	return "ALTER TABLE " + quoteIdent(at.Name) + " " + at.Alteration.SQL()
+}
+
+func (dt DropTable) SQL() string { return "DROP TABLE " + quoteIdent(dt.Name) }
+func (di DropIndex) SQL() string { return "DROP INDEX " + quoteIdent(di.Name) }
+
+func (ac AddColumn) SQL() string  { return "ADD COLUMN " + ac.Def.SQL() }
+func (dc DropColumn) SQL() string { return "DROP COLUMN " + quoteIdent(dc.Name) }
+
+func (ac AlterColumn) SQL() string {
+	s := "ALTER COLUMN " + quoteIdent(ac.Name) + " " + ac.Type.SQL()
+	if ac.NotNull {
+		s += " NOT NULL"
+	}
+	return s
+}
+
+func (rc RenameColumn) SQL() string {
+	return "RENAME COLUMN " + quoteIdent(rc.From) + " TO " + quoteIdent(rc.To)
+}
+
+func (ac AddConstraint) SQL() string  { return "ADD " + ac.Constraint.SQL() }
+func (dc DropConstraint) SQL() string { return "DROP CONSTRAINT " + quoteIdent(dc.Name) }
+
+func (onDeleteNoAction) SQL() string { return "SET ON DELETE NO ACTION" }
+
+func (cd ColumnDef) SQL() string {
+	s := quoteIdent(cd.Name) + " " + cd.Type.SQL()
+	if cd.NotNull {
+		s += " NOT NULL"
+	}
+	return s
+}
+
+func (t Type) SQL() string {
+	var base string
+	switch t.Base {
+	case Bool:
+		base = "BOOL"
+	case Int64:
+		base = "INT64"
+	case Float64:
+		base = "FLOAT64"
+	case Timestamp:
+		base = "TIMESTAMP"
+	case Date:
+		base = "DATE"
+	case String:
+		base = "STRING(" + lenSQL(t.Len) + ")"
+	case Bytes:
+		base = "BYTES(" + lenSQL(t.Len) + ")"
+	}
+	if t.Array {
+		return "ARRAY<" + base + ">"
+	}
+	return base
+}
+
+func lenSQL(n int64) string {
+	if n == MaxLen {
+		return "MAX"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func (kp KeyPart) SQL() string {
+	s := quoteIdent(kp.Column)
+	if kp.Desc {
+		s += " DESC"
+	}
+	return s
+}
+
+func keyPartListSQL(ks []KeyPart) string {
+	parts := make([]string, len(ks))
+	for i, k := range ks {
+		parts[i] = k.SQL()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func identListSQL(ids []string) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = quoteIdent(id)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (tc TableConstraint) SQL() string {
+	s := ""
+	if tc.Name != "" {
+		s = "CONSTRAINT " + quoteIdent(tc.Name) + " "
+	}
+	return s + tc.ForeignKey.SQL()
+}
+
+func (fk ForeignKey) SQL() string {
+	s := "FOREIGN KEY (" + identListSQL(fk.Columns) + ") REFERENCES " +
+		quoteIdent(fk.RefTable) + " (" + identListSQL(fk.RefColumns) + ")"
+	if fk.OnDelete == OnDeleteCascade {
+		s += " ON DELETE CASCADE"
+	}
+	return s
+}
+
+// SQL returns the query as SQL text.
+func (q Query) SQL() string {
+	s := q.Select.SQL()
+	if len(q.Order) > 0 {
+		parts := make([]string, len(q.Order))
+		for i, o := range q.Order {
+			parts[i] = o.SQL()
+		}
+		s += " ORDER BY " + strings.Join(parts, ", ")
+	}
+	if q.Limit != nil {
+		s += " LIMIT " + q.Limit.SQL()
+	}
+	return s
+}
+
+func (o Order) SQL() string {
+	s := o.Expr.SQL()
+	if o.Desc {
+		s += " DESC"
+	}
+	return s
+}
+
+// SQL returns the SELECT statement as SQL text.
+func (sel Select) SQL() string {
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	list := make([]string, len(sel.List))
+	for i, e := range sel.List {
+		list[i] = e.SQL()
+	}
+	sb.WriteString(strings.Join(list, ", "))
+	if len(sel.From) > 0 {
+		from := make([]string, len(sel.From))
+		for i, f := range sel.From {
+			from[i] = f.SQL()
+		}
+		sb.WriteString(" FROM " + strings.Join(from, ", "))
+	}
+	if sel.Where != nil {
+		sb.WriteString(" WHERE " + sel.Where.SQL())
+	}
+	if len(sel.GroupBy) > 0 {
+		gb := make([]string, len(sel.GroupBy))
+		for i, e := range sel.GroupBy {
+			gb[i] = e.SQL()
+		}
+		sb.WriteString(" GROUP BY " + strings.Join(gb, ", "))
+	}
+	if sel.Having != nil {
+		sb.WriteString(" HAVING " + sel.Having.SQL())
+	}
+	return sb.String()
+}
+
+func (t SelectFromTable) SQL() string {
+	s := quoteIdent(t.Table)
+	if t.Alias != "" {
+		s += " AS " + quoteIdent(t.Alias)
+	}
+	return s
+}
+
+var joinTypeSQL = map[JoinType]string{
+	InnerJoin: "INNER JOIN",
+	LeftJoin:  "LEFT OUTER JOIN",
+	RightJoin: "RIGHT OUTER JOIN",
+	FullJoin:  "FULL OUTER JOIN",
+	CrossJoin: "CROSS JOIN",
+}
+
+func (j SelectFromJoin) SQL() string {
+	s := j.LHS.SQL() + " " + joinTypeSQL[j.Type] + " " + j.RHS.SQL()
+	if j.On != nil {
+		s += " ON " + j.On.SQL()
+	} else if len(j.Using) > 0 {
+		s += " USING (" + identListSQL(j.Using) + ")"
+	}
+	return s
+}
+
+func (u SelectFromUnnest) SQL() string {
+	s := "UNNEST(" + u.Expr.SQL() + ")"
+	if u.Alias != "" {
+		s += " AS " + quoteIdent(u.Alias)
+	}
+	if u.WithOffset {
+		s += " WITH OFFSET"
+		if u.WithOffsetAlias != "" {
+			s += " AS " + quoteIdent(u.WithOffsetAlias)
+		}
+	}
+	return s
+}
+
+func (s SelectFromSubquery) SQL() string {
+	out := "(" + s.Query.SQL() + ")"
+	if s.Alias != "" {
+		out += " AS " + quoteIdent(s.Alias)
+	}
+	return out
+}
+
+// exprPrec returns the binding strength of e's top-level operator; lower
+// binds more loosely. It drives the parenthesization needed to round-trip
+// e.g. "(A OR B) AND C" versus "A OR B AND C".
+func exprPrec(e Expr) int {
+	switch v := e.(type) {
+	case LogicalOp:
+		switch v.Op {
+		case Or:
+			return 1
+		case And:
+			return 2
+		case Not:
+			return 3
+		}
+	case ComparisonOp, IsOp, InOp:
+		return 4
+	}
+	return 5
+}
+
+func sqlParen(e Expr, parentPrec int) string {
+	if exprPrec(e) < parentPrec {
+		return "(" + e.SQL() + ")"
+	}
+	return e.SQL()
+}
+
+func (l LogicalOp) SQL() string {
+	if l.Op == Not {
+		return "NOT " + sqlParen(l.RHS, 3)
+	}
+	prec, op := 2, "AND"
+	if l.Op == Or {
+		prec, op = 1, "OR"
+	}
+	return sqlParen(l.LHS, prec) + " " + op + " " + sqlParen(l.RHS, prec)
+}
+
+var comparisonOpSQL = map[ComparisonOperator]string{
+	Lt: "<", Le: "<=", Gt: ">", Ge: ">=", Eq: "=", Ne: "!=", Like: "LIKE", NotLike: "NOT LIKE",
+}
+
+func (c ComparisonOp) SQL() string {
+	return sqlParen(c.LHS, 4) + " " + comparisonOpSQL[c.Op] + " " + sqlParen(c.RHS, 4)
+}
+
+func (i IsOp) SQL() string {
+	s := sqlParen(i.LHS, 4) + " IS "
+	if i.Neg {
+		s += "NOT "
+	}
+	return s + i.RHS.SQL()
+}
+
+func (op InOp) SQL() string {
+	s := sqlParen(op.LHS, 4)
+	if op.Neg {
+		s += " NOT IN ("
+	} else {
+		s += " IN ("
+	}
+	if op.Query != nil {
+		s += op.Query.SQL()
+	} else {
+		vals := make([]string, len(op.Values))
+		for i, v := range op.Values {
+			vals[i] = v.SQL()
+		}
+		s += strings.Join(vals, ", ")
+	}
+	return s + ")"
+}
+
+func (fc FuncCall) SQL() string {
+	args := make([]string, len(fc.Args))
+	for i, a := range fc.Args {
+		args[i] = a.SQL()
+	}
+	prefix := ""
+	if fc.Distinct {
+		prefix = "DISTINCT "
+	}
+	return fc.Name + "(" + prefix + strings.Join(args, ", ") + ")"
+}
+
+func (starExpr) SQL() string { return "*" }
+
+func (sq ScalarSubquery) SQL() string { return "(" + sq.Query.SQL() + ")" }
+func (eq ExistsSubquery) SQL() string { return "EXISTS (" + eq.Query.SQL() + ")" }
+
+func (id ID) SQL() string {
+	parts := strings.Split(string(id), ".")
+	for i, p := range parts {
+		parts[i] = quoteIdent(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+func (p Param) SQL() string { return "@" + string(p) }
+
+func (lit IntegerLiteral) SQL() string { return strconv.FormatInt(int64(lit), 10) }
+
+func (lit FloatLiteral) SQL() string {
+	s := strconv.FormatFloat(float64(lit), 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+func (lit StringLiteral) SQL() string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range string(lit) {
+		switch r {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func (lit BoolLiteral) SQL() string {
+	if lit {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func (NullLiteral) SQL() string { return "NULL" }
+
+// reservedWords are identifiers that must be quoted with backticks when used
+// as table, column, or constraint names.
+var reservedWords = map[string]bool{
+	"ALL": true, "AND": true, "ANY": true, "ARRAY": true, "AS": true, "ASC": true,
+	"BETWEEN": true, "BY": true, "CASE": true, "CAST": true, "COLLATE": true,
+	"CREATE": true, "CROSS": true, "DEFAULT": true, "DESC": true,
+	"DISTINCT": true, "DROP": true, "ELSE": true, "END": true, "ENUM": true,
+	"ESCAPE": true, "EXCEPT": true, "EXISTS": true, "EXTRACT": true, "FALSE": true,
+	"FOLLOWING": true, "FOR": true, "FROM": true, "FULL": true,
+	"GROUP": true, "GROUPING": true, "HAVING": true, "IF": true, "IGNORE": true,
+	"IN": true, "INDEX": true, "INNER": true, "INTERSECT": true, "INTO": true,
+	"IS": true, "JOIN": true, "LEFT": true, "LIKE": true, "LIMIT": true,
+	"NATURAL": true, "NO": true, "NOT": true, "NULL": true, "NULLS": true, "ON": true,
+	"OR": true, "ORDER": true, "OUTER": true, "OVER": true, "PARTITION": true,
+	"PRECEDING": true, "PRIMARY": true, "RIGHT": true,
+	"SELECT": true, "SET": true, "TABLE": true, "THEN": true, "TO": true, "TRUE": true,
+	"UNION": true, "UNNEST": true, "USING": true, "WHEN": true, "WHERE": true,
+	"WITH": true, "WITHIN": true,
+}
+
+var plainIdentRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// quoteIdent renders id as SQL, quoting it with backticks if it isn't a
+// plain identifier or collides with a reserved word. Dotted identifiers
+// (e.g. "Orders.CustomerID") have each component quoted independently.
+func quoteIdent(id string) string {
+	if strings.Contains(id, ".") {
+		parts := strings.Split(id, ".")
+		for i, p := range parts {
+			parts[i] = quoteIdent(p)
+		}
+		return strings.Join(parts, ".")
+	}
+	if plainIdentRE.MatchString(id) && !reservedWords[strings.ToUpper(id)] {
+		return id
+	}
+	return "`" + strings.ReplaceAll(id, "`", "``") + "`"
+}
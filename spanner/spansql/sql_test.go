@@ -0,0 +1,141 @@
+/*
+Copyright 2019 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spansql
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSQLRoundTrip re-parses the SQL text produced by SQL() and checks it
+// reconstructs an equal AST, for the same query/DDL corpus exercised by
+// TestParseQuery, TestParseQueryGroupByHaving, TestParseQueryJoinsAndSubqueries
+// and TestParseDDL/TestParseDDLForeignKey.
+func TestSQLRoundTrip(t *testing.T) {
+	queries := []string{
+		`SELECT 17`,
+		`SELECT Alias FROM Characters WHERE Age < @ageLimit AND Alias IS NOT NULL ORDER BY Age DESC LIMIT @limit`,
+		`SELECT Country, COUNT(*) FROM Singers GROUP BY Country HAVING COUNT(*) > 1`,
+		`SELECT SingerID, SUM(Amount), AVG(Amount), MIN(Amount), MAX(Amount), COUNT(DISTINCT Amount) FROM Sales GROUP BY SingerID`,
+		`SELECT 1 FROM A INNER JOIN B ON A.X = B.X LEFT OUTER JOIN C USING (Y)`,
+		`SELECT 1 FROM UNNEST(Ids) AS Id WITH OFFSET AS Pos`,
+		`SELECT 1 FROM (SELECT SingerID FROM Singers) AS S WHERE S.SingerID IN (SELECT SingerID FROM Albums WHERE Albums.SingerID = S.SingerID)`,
+		`SELECT A OR B AND C`,
+		`SELECT (A OR B) AND C`,
+		`SELECT * FROM Singers`,
+	}
+	for _, in := range queries {
+		q, err := ParseQuery(in)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", in, err)
+			continue
+		}
+		sql := q.SQL()
+		got, err := ParseQuery(sql)
+		if err != nil {
+			t.Errorf("ParseQuery(%q).SQL() = %q, which failed to reparse: %v", in, sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, q) {
+			t.Errorf("ParseQuery(%q).SQL() = %q; reparsed as %#v, want %#v", in, sql, got, q)
+		}
+	}
+
+	ddls := []string{
+		`CREATE TABLE FooBar (
+			System STRING(MAX) NOT NULL,
+			RepoPath STRING(MAX) NOT NULL,
+			Count INT64,
+		) PRIMARY KEY(System, RepoPath);
+		CREATE INDEX MyFirstIndex ON FooBar (Count DESC);
+		ALTER TABLE FooBar ADD COLUMN TZ BYTES(20);
+		ALTER TABLE FooBar DROP COLUMN TZ;
+		ALTER TABLE FooBar SET ON DELETE NO ACTION;
+		DROP INDEX MyFirstIndex;
+		DROP TABLE FooBar;
+		CREATE TABLE NonScalars (
+			Dummy INT64 NOT NULL,
+			Ids ARRAY<INT64>,
+			Names ARRAY<STRING(MAX)>,
+		) PRIMARY KEY (Dummy);`,
+		`CREATE TABLE Orders (
+			OrderID INT64 NOT NULL,
+			CustomerID INT64 NOT NULL,
+			CONSTRAINT FK_Customer FOREIGN KEY (CustomerID) REFERENCES Customers (CustomerID),
+		) PRIMARY KEY (OrderID)`,
+		`CREATE TABLE Items (
+			ItemID INT64 NOT NULL,
+			OrderID INT64 NOT NULL,
+			FOREIGN KEY (OrderID) REFERENCES Orders (OrderID) ON DELETE CASCADE,
+		) PRIMARY KEY (ItemID)`,
+		`ALTER TABLE Orders ADD CONSTRAINT FK_Customer FOREIGN KEY (CustomerID) REFERENCES Customers (CustomerID) ON DELETE NO ACTION`,
+		`ALTER TABLE Orders DROP CONSTRAINT FK_Customer`,
+		`ALTER TABLE FooBar ALTER COLUMN Count INT64 NOT NULL`,
+		`ALTER TABLE FooBar RENAME COLUMN Count TO Total`,
+		`CREATE TABLE Songs (
+			SingerID INT64 NOT NULL,
+			SongID INT64 NOT NULL,
+			Title STRING(MAX),
+		) PRIMARY KEY (SingerID, SongID),
+		INTERLEAVE IN PARENT Singers ON DELETE CASCADE`,
+	}
+	for _, in := range ddls {
+		d, err := ParseDDL(in)
+		if err != nil {
+			t.Errorf("ParseDDL(%q): %v", in, err)
+			continue
+		}
+		sql := d.SQL()
+		got, err := ParseDDL(sql)
+		if err != nil {
+			t.Errorf("ParseDDL(%q).SQL() = %q, which failed to reparse: %v", in, sql, err)
+			continue
+		}
+		// Position records where a statement appeared in its source text,
+		// which necessarily differs between the original and the
+		// re-serialized SQL; it's not part of what a round trip preserves.
+		clearPositions(got)
+		clearPositions(d)
+		if !reflect.DeepEqual(got, d) {
+			t.Errorf("ParseDDL(%q).SQL() = %q; reparsed as %#v, want %#v", in, sql, got, d)
+		}
+	}
+}
+
+// clearPositions zeroes out the Position of every statement in d, in place,
+// so DDLs that differ only in source location compare equal.
+func clearPositions(d DDL) {
+	for i, stmt := range d.List {
+		switch v := stmt.(type) {
+		case CreateTable:
+			v.Position = Position{}
+			d.List[i] = v
+		case CreateIndex:
+			v.Position = Position{}
+			d.List[i] = v
+		case AlterTable:
+			v.Position = Position{}
+			d.List[i] = v
+		case DropTable:
+			v.Position = Position{}
+			d.List[i] = v
+		case DropIndex:
+			v.Position = Position{}
+			d.List[i] = v
+		}
+	}
+}
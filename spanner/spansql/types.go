@@ -0,0 +1,444 @@
+/*
+Copyright 2019 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package spansql supports the Cloud Spanner SQL dialect.
+//
+// It is divided into two main parts: a parser for the data definition
+// language (DDL) and query language, and the types representing the parsed
+// statements and expressions.
+package spansql
+
+import "math"
+
+// DDL represents a sequence of DDL statements.
+type DDL struct {
+	List []DDLStmt
+}
+
+// Position describes a source position, as a 1-based line and column within
+// the text that was parsed.
+type Position struct {
+	Line, Column int
+}
+
+// DDLStmt represents a DDL statement.
+type DDLStmt interface {
+	isDDLStmt()
+	SQL() string
+	Pos() Position
+}
+
+func (CreateTable) isDDLStmt() {}
+func (CreateIndex) isDDLStmt() {}
+func (AlterTable) isDDLStmt()  {}
+func (DropTable) isDDLStmt()   {}
+func (DropIndex) isDDLStmt()   {}
+
+func (ct CreateTable) Pos() Position { return ct.Position }
+func (ci CreateIndex) Pos() Position { return ci.Position }
+func (at AlterTable) Pos() Position  { return at.Position }
+func (dt DropTable) Pos() Position   { return dt.Position }
+func (di DropIndex) Pos() Position   { return di.Position }
+
+// CreateTable represents a CREATE TABLE statement.
+type CreateTable struct {
+	Name        string
+	Columns     []ColumnDef
+	Constraints []TableConstraint
+	PrimaryKey  []KeyPart
+	Interleave  *Interleave // may be nil
+
+	Position Position
+}
+
+// Interleave represents a table's "INTERLEAVE IN PARENT" clause, which nests
+// a table's rows inside its parent's.
+type Interleave struct {
+	Parent   string
+	OnDelete OnDeleteAction
+}
+
+// CreateIndex represents a CREATE INDEX statement.
+type CreateIndex struct {
+	Name    string
+	Table   string
+	Columns []KeyPart
+
+	Position Position
+}
+
+// AlterTable represents an ALTER TABLE statement.
+type AlterTable struct {
+	Name       string
+	Alteration Alteration
+
+	Position Position
+}
+
+// DropTable represents a DROP TABLE statement.
+type DropTable struct {
+	Name string
+
+	Position Position
+}
+
+// DropIndex represents a DROP INDEX statement.
+type DropIndex struct {
+	Name string
+
+	Position Position
+}
+
+// Alteration is an alteration to a table as part of an ALTER TABLE statement.
+type Alteration interface {
+	isAlteration()
+	SQL() string
+}
+
+func (AddColumn) isAlteration()      {}
+func (DropColumn) isAlteration()     {}
+func (AlterColumn) isAlteration()    {}
+func (RenameColumn) isAlteration()   {}
+func (AddConstraint) isAlteration()  {}
+func (DropConstraint) isAlteration() {}
+
+// AddColumn is an ALTER TABLE alteration that adds a column to a table.
+type AddColumn struct {
+	Def ColumnDef
+}
+
+// DropColumn is an ALTER TABLE alteration that removes a column from a table.
+type DropColumn struct {
+	Name string
+}
+
+// AlterColumn is an ALTER TABLE alteration that changes the type or
+// nullability of an existing column.
+type AlterColumn struct {
+	Name    string
+	Type    Type
+	NotNull bool
+}
+
+// RenameColumn is an ALTER TABLE alteration that renames an existing column.
+type RenameColumn struct {
+	From, To string
+}
+
+// AddConstraint is an ALTER TABLE alteration that adds a table constraint.
+type AddConstraint struct {
+	Constraint TableConstraint
+}
+
+// DropConstraint is an ALTER TABLE alteration that removes a named constraint.
+type DropConstraint struct {
+	Name string
+}
+
+// onDeleteNoAction is the Alteration for "ALTER TABLE ... SET ON DELETE NO ACTION".
+type onDeleteNoAction struct{}
+
+func (onDeleteNoAction) isAlteration() {}
+
+// NoActionOnDelete is the Alteration used by
+// "ALTER TABLE <table> SET ON DELETE NO ACTION".
+var NoActionOnDelete Alteration = onDeleteNoAction{}
+
+// ColumnDef represents a column definition as part of a CREATE TABLE
+// or ALTER TABLE ADD COLUMN statement.
+type ColumnDef struct {
+	Name    string
+	Type    Type
+	NotNull bool
+}
+
+// KeyPart represents a column specification as part of a primary key or index definition.
+type KeyPart struct {
+	Column string
+	Desc   bool
+}
+
+// TableConstraint represents a table-level constraint, such as a foreign key.
+//
+// Spanner doesn't reserve CONSTRAINT, FOREIGN, KEY or REFERENCES, so a
+// column may be named after one of them; the parser treats an item of a
+// CREATE TABLE column list as a table constraint only when it reads
+// "[CONSTRAINT <name>] FOREIGN KEY", and as a column definition otherwise.
+type TableConstraint struct {
+	// Name is the name given via "CONSTRAINT <name>"; it may be empty for an
+	// unnamed constraint.
+	Name       string
+	ForeignKey ForeignKey
+}
+
+// ForeignKey represents a FOREIGN KEY table constraint:
+//
+//	FOREIGN KEY ( Columns... ) REFERENCES RefTable ( RefColumns... ) [ON DELETE OnDelete]
+type ForeignKey struct {
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   OnDeleteAction
+}
+
+// OnDeleteAction is the action to take when a referenced row of a foreign
+// key is deleted.
+type OnDeleteAction int
+
+const (
+	// OnDeleteNoAction is the default; Spanner verifies there are no
+	// referencing rows before permitting the delete.
+	OnDeleteNoAction OnDeleteAction = iota
+	// OnDeleteCascade causes referencing rows to be deleted along with the
+	// referenced row.
+	OnDeleteCascade
+)
+
+// Type represents a column type.
+type Type struct {
+	Array bool
+	Base  TypeBase
+	Len   int64 // if Base is String or Bytes; may be MaxLen
+}
+
+// MaxLen is a sentinel for Type's Len field, representing the MAX keyword.
+const MaxLen = math.MaxInt64
+
+// TypeBase represents the base type of a Type.
+type TypeBase int
+
+const (
+	Bool TypeBase = iota
+	Int64
+	Float64
+	Timestamp
+	Date
+	String
+	Bytes
+)
+
+// Query represents a query statement.
+type Query struct {
+	Select Select
+	Order  []Order
+	Limit  Expr // may be nil
+}
+
+// Select represents a SELECT statement.
+type Select struct {
+	List    []Expr
+	From    []SelectFrom
+	Where   Expr   // may be nil
+	GroupBy []Expr // may be nil
+	Having  Expr   // may be nil
+}
+
+// SelectFrom represents a FROM clause source: a table, a JOIN, an UNNEST, or
+// a subquery.
+type SelectFrom interface {
+	isSelectFrom()
+	SQL() string
+}
+
+func (SelectFromTable) isSelectFrom()    {}
+func (SelectFromJoin) isSelectFrom()     {}
+func (SelectFromUnnest) isSelectFrom()   {}
+func (SelectFromSubquery) isSelectFrom() {}
+
+// SelectFromTable is a FROM clause source that names a table directly,
+// such as "FROM Characters" or "FROM Characters AS C".
+type SelectFromTable struct {
+	Table string
+	Alias string // may be empty
+}
+
+// JoinType is the kind of join in a SelectFromJoin.
+type JoinType int
+
+const (
+	InnerJoin JoinType = iota
+	LeftJoin
+	RightJoin
+	FullJoin
+	CrossJoin
+)
+
+// SelectFromJoin is a FROM clause source that joins two other sources.
+//
+// At most one of On and Using will be set, and neither will be set if
+// Type is CrossJoin.
+type SelectFromJoin struct {
+	Type     JoinType
+	LHS, RHS SelectFrom
+	On       Expr     // may be nil
+	Using    []string // may be nil
+}
+
+// SelectFromUnnest is a FROM clause source of the form "UNNEST(expr)",
+// optionally aliased and optionally paired with "WITH OFFSET".
+type SelectFromUnnest struct {
+	Expr  Expr
+	Alias string // may be empty
+
+	WithOffset      bool
+	WithOffsetAlias string // may be empty; only meaningful if WithOffset
+}
+
+// SelectFromSubquery is a FROM clause source of the form "(SELECT ...)",
+// optionally aliased.
+type SelectFromSubquery struct {
+	Query Query
+	Alias string // may be empty
+}
+
+// Order represents an ORDER BY clause item.
+type Order struct {
+	Expr Expr
+	Desc bool
+}
+
+// Expr represents a SQL expression.
+type Expr interface {
+	isExpr()
+	SQL() string
+}
+
+func (IntegerLiteral) isExpr() {}
+func (FloatLiteral) isExpr()   {}
+func (StringLiteral) isExpr()  {}
+func (ID) isExpr()             {}
+func (Param) isExpr()          {}
+func (LogicalOp) isExpr()      {}
+func (ComparisonOp) isExpr()   {}
+func (IsOp) isExpr()           {}
+func (FuncCall) isExpr()       {}
+func (starExpr) isExpr()       {}
+func (ScalarSubquery) isExpr() {}
+func (ExistsSubquery) isExpr() {}
+func (InOp) isExpr()           {}
+
+type (
+	// ID is an identifier representing a table or column name.
+	ID string
+	// Param is a query parameter, such as "@ageLimit".
+	Param string
+
+	// IntegerLiteral is an integer literal.
+	IntegerLiteral int64
+	// FloatLiteral is a floating point literal.
+	FloatLiteral float64
+	// StringLiteral is a string literal.
+	StringLiteral string
+
+	// BoolLiteral is a boolean literal.
+	BoolLiteral bool
+
+	// NullLiteral is the NULL literal.
+	NullLiteral int
+)
+
+func (BoolLiteral) isExpr() {}
+func (NullLiteral) isExpr() {}
+
+// Known boolean and null literals.
+const (
+	True  = BoolLiteral(true)
+	False = BoolLiteral(false)
+
+	Null = NullLiteral(0)
+)
+
+// LogicalOperator is the operator in a LogicalOp.
+type LogicalOperator int
+
+const (
+	And LogicalOperator = iota
+	Or
+	Not
+)
+
+// LogicalOp represents a logical operator expression: AND, OR, NOT.
+//
+// For the unary NOT operator, LHS is nil.
+type LogicalOp struct {
+	LHS Expr
+	Op  LogicalOperator
+	RHS Expr
+}
+
+// ComparisonOperator is the operator in a ComparisonOp.
+type ComparisonOperator int
+
+const (
+	Lt ComparisonOperator = iota
+	Le
+	Gt
+	Ge
+	Eq
+	Ne
+	Like
+	NotLike
+)
+
+// ComparisonOp represents a comparison operator expression.
+type ComparisonOp struct {
+	LHS, RHS Expr
+	Op       ComparisonOperator
+}
+
+// IsOp represents an IS [NOT] expression, such as "X IS NOT NULL".
+type IsOp struct {
+	LHS Expr
+	Neg bool
+	RHS Expr // one of Null, True, False
+}
+
+// FuncCall represents a function call expression, such as an aggregate
+// function (COUNT, SUM, AVG, MIN, MAX) used in a SELECT list, GROUP BY, or
+// HAVING clause.
+type FuncCall struct {
+	Name     string
+	Args     []Expr
+	Distinct bool // set for e.g. COUNT(DISTINCT x)
+}
+
+// starExpr is the unexported type behind Star.
+type starExpr struct{}
+
+// Star represents "*", either as an item of a SELECT list ("SELECT *") or
+// as the argument of a function call such as COUNT(*).
+var Star Expr = starExpr{}
+
+// ScalarSubquery represents a scalar subquery used as an expression, such as
+// "(SELECT COUNT(*) FROM Singers)".
+type ScalarSubquery struct {
+	Query Query
+}
+
+// ExistsSubquery represents an "EXISTS (SELECT ...)" expression.
+type ExistsSubquery struct {
+	Query Query
+}
+
+// InOp represents a "X [NOT] IN (...)" expression, where the right-hand
+// side is either a subquery or a list of values; exactly one of Query and
+// Values is set.
+type InOp struct {
+	LHS    Expr
+	Neg    bool
+	Query  *Query
+	Values []Expr
+}